@@ -2,7 +2,9 @@ package commands
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,20 +17,34 @@ type Executor func(args ...string) error
 type Command struct {
 	Name        string
 	Description string
-	Fn          Executor
+	// Usage describes how to call the command, ie. "usage: tack render
+	// <permalink> [sitedir]".
+	Usage string
+	// Setup defines the command's own flags on the given flag set and returns
+	// the function executing the command using the parsed flag values. The
+	// flags are parsed from the arguments following the verb, where the
+	// global flags are accepted, too. Commands without flags of their own
+	// only need to set Fn.
+	Setup func(flags *flag.FlagSet) Executor
+	Fn    Executor
 }
 
 var List []Command
 
+// RegisterCommand adds a command without flags of its own to the list of
+// available verbs.
 func RegisterCommand(name string, desc string, fn Executor) {
-	cmd := Command{Name: name, Description: desc, Fn: fn}
+	Register(Command{Name: name, Description: desc, Fn: fn})
+}
 
+// Register adds the command to the list of available verbs.
+func Register(cmd Command) {
 	if len(List) == 0 {
 		List = []Command{cmd}
 		return
 	}
 
-	if strings.Compare(List[0].Name, name) < 0 {
+	if strings.Compare(List[0].Name, cmd.Name) < 0 {
 		List = append(List, cmd)
 	} else {
 		List = append([]Command{cmd}, List...)
@@ -36,7 +52,92 @@ func RegisterCommand(name string, desc string, fn Executor) {
 	}
 }
 
+// FindCommand returns the command of the given name, or nil if there is no
+// such command.
+func FindCommand(name string) *Command {
+	for idx := range List {
+		if List[idx].Name == name {
+			return &List[idx]
+		}
+	}
+
+	return nil
+}
+
+// setup returns a new flag set containing the command's own flags, as well as
+// the function executing the command using their values.
+func (c *Command) setup() (*flag.FlagSet, Executor) {
+	flags := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	if c.Setup == nil {
+		return flags, c.Fn
+	}
+
+	return flags, c.Setup(flags)
+}
+
+// Run parses the command's flags, as well as the global flags, from the given
+// arguments and executes the command using the remaining positional ones.
+func (c *Command) Run(args []string) error {
+	flags, fn := c.setup()
+	flag.CommandLine.VisitAll(func(fl *flag.Flag) {
+		if flags.Lookup(fl.Name) == nil {
+			flags.Var(fl.Value, fl.Name, fl.Usage)
+		}
+	})
+
+	args, err := parseFlags(flags, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return Help(c.Name)
+	} else if err != nil {
+		return fmt.Errorf("%s\nRun 'tack help %s' for usage.", err, c.Name)
+	}
+
+	return fn(args...)
+}
+
+// runCommand runs the command of the given name, which needs to be registered.
+func runCommand(name string, args []string) error {
+	return FindCommand(name).Run(args)
+}
+
+// parseFlags parses the given arguments using a verb's flag set, allowing
+// flags and positional arguments to be mixed. The positional arguments are
+// returned.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// explainError adds a hint on how to debug rendering errors in strict mode.
+func explainError(err error) error {
+	var renderErr *core.RenderError
+	if StrictMode && errors.As(err, &renderErr) {
+		return fmt.Errorf("%w\nRun 'tack vars %s' to list all variables available to the template.", err, renderErr.Permalink)
+	}
+
+	return err
+}
+
+// newTackerWithArgs creates a Tacker for the site directory given as argument,
+// or the working directory, configured using the global flags.
 func newTackerWithArgs(args ...string) (*core.Tacker, error) {
+	return newTacker(DraftsMode, FutureMode, args...)
+}
+
+// newTacker works like newTackerWithArgs, but includes drafts and scheduled
+// posts as requested instead of using the global flags.
+func newTacker(drafts bool, future bool, args ...string) (*core.Tacker, error) {
 	if len(args) > 1 {
 		return nil, errors.New("too many arguments")
 	}
@@ -56,7 +157,7 @@ func newTackerWithArgs(args ...string) (*core.Tacker, error) {
 		dir = cwd
 	}
 
-	t, err := core.NewTacker(dir)
+	t, err := core.NewTackerWithOptions(dir, core.Options{Drafts: drafts, Future: future})
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +167,16 @@ func newTackerWithArgs(args ...string) (*core.Tacker, error) {
 	}
 
 	t.Strict = StrictMode
+	t.Incremental = IncrementalMode
+	t.Jobs = Jobs
+
+	if OutputDir != "" {
+		d, err := filepath.Abs(OutputDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve directory %s: %s", OutputDir, err)
+		}
+		t.TargetDir = d
+	}
 
 	return t, nil
 }
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	format := flags.String("format", "text", "")
+	verbose := flags.Bool("v", false, "")
+
+	args, err := parseFlags(flags, []string{"first", "--format", "json", "second", "-v", "third"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, args)
+	assert.Equal(t, "json", *format)
+	assert.True(t, *verbose)
+
+	// everything following "--" is positional
+	args, err = parseFlags(flags, []string{"--", "-v", "first"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-v", "first"}, args)
+
+	_, err = parseFlags(flags, []string{"first", "--unknown"})
+	assert.Error(t, err)
+}
+
+func TestCommandRun(t *testing.T) {
+	defer func(strict bool, drafts bool) {
+		StrictMode = strict
+		DraftsMode = drafts
+	}(StrictMode, DraftsMode)
+	StrictMode = false
+	DraftsMode = false
+
+	var gotArgs []string
+	var gotOpt string
+	var gotDrafts bool
+	cmd := Command{
+		Name: "test",
+		Setup: func(flags *flag.FlagSet) Executor {
+			opt := flags.String("opt", "default", "")
+			drafts := flags.Bool("drafts", true, "")
+			return func(args ...string) error {
+				gotArgs, gotOpt, gotDrafts = args, *opt, *drafts
+				return nil
+			}
+		},
+	}
+
+	// global flags are accepted after the verb, too
+	assert.NoError(t, cmd.Run([]string{"a", "-s", "--opt", "value", "b", "--drafts=false"}))
+	assert.Equal(t, []string{"a", "b"}, gotArgs)
+	assert.Equal(t, "value", gotOpt)
+	assert.False(t, gotDrafts)
+	assert.True(t, StrictMode)
+	// overridden by the verb, so the global flag is left alone
+	assert.False(t, DraftsMode)
+
+	// the flag values of a previous run are not kept
+	assert.NoError(t, cmd.Run([]string{"c"}))
+	assert.Equal(t, []string{"c"}, gotArgs)
+	assert.Equal(t, "default", gotOpt)
+	assert.True(t, gotDrafts)
+
+	err := cmd.Run([]string{"--unknown"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Run 'tack help test' for usage.")
+}
+
+func TestRegisterCommand(t *testing.T) {
+	defer func(list []Command) {
+		List = list
+	}(List)
+
+	called := false
+	RegisterCommand("zzz-test", "Tests the old API", func(args ...string) error {
+		called = true
+		assert.Equal(t, []string{"x"}, args)
+		return nil
+	})
+
+	cmd := FindCommand("zzz-test")
+	if assert.NotNil(t, cmd) {
+		assert.Equal(t, "Tests the old API", cmd.Description)
+		assert.NoError(t, cmd.Run([]string{"x"}))
+		assert.True(t, called)
+	}
+	assert.Nil(t, FindCommand("nonexistent"))
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/roblillack/tack/core"
+)
+
+// snippetContext is the number of lines shown before and after the line an
+// error occurred in.
+const snippetContext = 3
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Tack error</title>
+    <style>
+      body { font-family: sans-serif; margin: 3rem 10%; color: #222; }
+      h1 { color: #b00020; font-size: 1.5rem; }
+      dt { font-weight: bold; }
+      dd { margin: 0 0 .5rem 0; font-family: monospace; }
+      pre { background: #f6f6f6; border: 1px solid #ddd; padding: .5rem 0; overflow-x: auto; }
+      pre span { display: block; padding: 0 1rem; }
+      pre span.error { background: #fdd; }
+      .message { white-space: pre-wrap; font-family: monospace; font-size: 1.1rem; }
+      .hint { color: #666; }
+    </style>
+  </head>
+  <body>
+    <h1>Unable to tack the site</h1>
+    <p class="message">{{.Message}}</p>
+    <dl>
+      {{with .Permalink}}<dt>Page</dt><dd>{{.}}</dd>{{end}}
+      {{with .Template}}<dt>Template</dt><dd>{{.}}</dd>{{end}}
+      {{with .Filename}}<dt>File</dt><dd>{{.}}{{with $.Line}}, line {{.}}{{end}}{{with $.Column}}, column {{.}}{{end}}</dd>{{end}}
+    </dl>
+    {{with .Snippet}}<pre>{{range .}}<span{{if .Error}} class="error"{{end}}>{{printf "%4d" .Number}}  {{.Text}}</span>{{end}}</pre>{{end}}
+    <p class="hint">This page will reload automatically once the problem is fixed.</p>
+    {{.Script}}
+  </body>
+</html>
+`))
+
+type errorPageData struct {
+	Message   string
+	Permalink string
+	Template  string
+	Filename  string
+	Line      int
+	Column    int
+	Snippet   []snippetLine
+	Script    template.HTML
+}
+
+type snippetLine struct {
+	Number int
+	Text   string
+	Error  bool
+}
+
+// writeErrorPage renders an HTML page describing the error, including the
+// failing file and the surrounding source, if known.
+func writeErrorPage(w io.Writer, err error) error {
+	data := errorPageData{Message: err.Error(), Script: template.HTML(liveReloadScript)}
+
+	var renderErr *core.RenderError
+	if errors.As(err, &renderErr) {
+		data.Permalink = renderErr.Permalink
+		data.Template = renderErr.Template
+		if data.Template == "" {
+			data.Template = "default"
+		}
+	}
+
+	var sourceErr *core.SourceError
+	if errors.As(err, &sourceErr) {
+		data.Permalink = sourceErr.Permalink
+		data.Filename = sourceErr.Filename
+		data.Line = sourceErr.Line
+		data.Column = sourceErr.Column
+		data.Snippet = sourceSnippet(sourceErr.Filename, sourceErr.Line)
+	}
+
+	return errorPage.Execute(w, data)
+}
+
+// sourceSnippet returns the lines surrounding the given line of a file.
+func sourceSnippet(filename string, line int) []snippetLine {
+	if line < 1 {
+		return nil
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	r := []snippetLine{}
+	for i := line - snippetContext; i <= line+snippetContext; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		r = append(r, snippetLine{Number: i, Text: lines[i-1], Error: i == line})
+	}
+
+	return r
+}
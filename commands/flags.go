@@ -3,9 +3,36 @@ package commands
 import "flag"
 
 var DebugMode bool
+var DraftsMode bool
+var FutureMode bool
 var StrictMode bool
+var IncrementalMode bool
+var Jobs int
+var OutputDir string
 
 func init() {
 	flag.BoolVar(&DebugMode, "d", false, "Print debugging information during site builds")
+	flag.BoolVar(&DraftsMode, "drafts", false, "Include pages marked as drafts")
+	flag.BoolVar(&FutureMode, "future", false, "Include posts dated in the future")
+	flag.BoolVar(&IncrementalMode, "i", false, "Enable incremental mode (only re-generates outputs whose sources changed)")
+	flag.IntVar(&Jobs, "j", 0, "Number of pages to generate in parallel (defaults to the number of CPUs)")
+	flag.StringVar(&OutputDir, "o", "", "Output directory (defaults to output/ inside the site directory)")
 	flag.BoolVar(&StrictMode, "s", false, "Enable strict mode (fails when trying to render undefined variables)")
 }
+
+// globalBool returns value, if the global flag of the given name has been set
+// on the command line, or def otherwise. This allows verbs to use different
+// defaults for the global flags they define themselves.
+func globalBool(name string, value bool, def bool) bool {
+	set := false
+	flag.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	if set {
+		return value
+	}
+
+	return def
+}
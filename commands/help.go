@@ -6,29 +6,68 @@ import (
 )
 
 func init() {
-	RegisterCommand("help", "Displays this help screen", Help)
+	Register(Command{
+		Name:        "help",
+		Description: "Displays this help screen",
+		Usage:       "usage: tack help [<verb>]",
+		Fn:          Help,
+	})
 	flag.Usage = func() { _ = Help() }
 }
 
 var Version = "0.0.0-dev"
 
+// Help prints the list of all verbs and global flags, or, if a verb is given,
+// the usage and flags of this verb.
 func Help(args ...string) error {
+	if len(args) > 0 {
+		cmd := FindCommand(args[0])
+		if cmd == nil {
+			return fmt.Errorf("unknown verb: %s", args[0])
+		}
+		usage := cmd.Usage
+		if usage == "" {
+			usage = "usage: tack " + cmd.Name
+		}
+		fmt.Printf("%s\n\n%s.\n", usage, cmd.Description)
+		flags, _ := cmd.setup()
+		printFlags("Flags", flags, nil)
+		return nil
+	}
+
 	fmt.Printf(`tack %s
 
-usage: tack [-d] [<verb>] [parameters]
+usage: tack [<global flags>] [<verb>] [<flags>] [parameters]
 
 Available verbs:
 `, Version)
 	for _, i := range List {
-		fmt.Printf("    %-15s %s\n", i.Name, i.Description)
+		fmt.Printf("    %-19s %s\n", i.Name, i.Description)
 	}
 
-	fmt.Println("\nAvailable global flags:")
-
-	flag.CommandLine.VisitAll(func(fl *flag.Flag) {
-		_, usage := flag.UnquoteUsage(fl)
-		fmt.Printf("    -%-14s %s\n", fl.Name, usage)
-	})
+	printFlags("Available global flags", flag.CommandLine, nil)
+	fmt.Println("\nRun 'tack help <verb>' to learn about the flags of a verb.")
 
 	return nil
 }
+
+// printFlags lists all flags of the set for which filter returns true.
+func printFlags(title string, flags *flag.FlagSet, filter func(fl *flag.Flag) bool) {
+	header := false
+	flags.VisitAll(func(fl *flag.Flag) {
+		if filter != nil && !filter(fl) {
+			return
+		}
+		if !header {
+			fmt.Printf("\n%s:\n", title)
+			header = true
+		}
+		name, usage := flag.UnquoteUsage(fl)
+		if name != "" {
+			name = fl.Name + " " + name
+		} else {
+			name = fl.Name
+		}
+		fmt.Printf("    -%-18s %s\n", name, usage)
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isAddrInUse returns true if listening failed because the address is in use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
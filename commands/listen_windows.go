@@ -0,0 +1,15 @@
+package commands
+
+import (
+	"errors"
+	"syscall"
+)
+
+// wsaeaddrinuse is the error code Windows uses for addresses already in use,
+// which differs from the syscall.EADDRINUSE value defined for Windows.
+const wsaeaddrinuse = syscall.Errno(10048)
+
+// isAddrInUse returns true if listening failed because the address is in use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, wsaeaddrinuse) || errors.Is(err, syscall.EADDRINUSE)
+}
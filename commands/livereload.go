@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// liveReloadPath is the endpoint browsers connect to in order to get notified
+// about finished rebuilds using server-sent events.
+const liveReloadPath = "/_tack/events"
+
+// liveReloadScript is injected into all HTML pages delivered by `tack serve`.
+// It is never written to the output directory.
+const liveReloadScript = `<script>new EventSource("` + liveReloadPath + `").addEventListener("reload", function() { location.reload(); });</script>`
+
+// reloader keeps track of all connected browsers and notifies them once a
+// rebuild has finished.
+type reloader struct {
+	mutex   sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newReloader() *reloader {
+	return &reloader{clients: map[chan struct{}]struct{}{}}
+}
+
+// Notify sends a reload event to all connected browsers.
+func (r *reloader) Notify() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for c := range r.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+			// a reload is already pending for this client
+		}
+	}
+}
+
+// ServeHTTP implements the server-sent events endpoint.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan struct{}, 1)
+	r.mutex.Lock()
+	r.clients[c] = struct{}{}
+	r.mutex.Unlock()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.clients, c)
+		r.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-c:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// injectingWriter buffers HTML responses to add the live reload script before
+// sending them to the browser. All other responses, as well as responses to
+// HEAD requests, which do not have a body, are passed through as is.
+type injectingWriter struct {
+	http.ResponseWriter
+	head   bool
+	status int
+	html   *bytes.Buffer
+}
+
+func newInjectingWriter(w http.ResponseWriter, req *http.Request) *injectingWriter {
+	return &injectingWriter{ResponseWriter: w, head: req.Method == http.MethodHead}
+}
+
+func (w *injectingWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if !w.head && status == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		w.html = &bytes.Buffer{}
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *injectingWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.html != nil {
+		return w.html.Write(data)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Close sends a buffered HTML response, including the live reload script.
+func (w *injectingWriter) Close() error {
+	if w.html == nil {
+		return nil
+	}
+
+	body := injectScript(w.html.Bytes(), liveReloadScript)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
+
+// injectScript adds the script right before the closing body tag of the HTML
+// document, or at its end, if there is none.
+func injectScript(html []byte, script string) []byte {
+	idx := bytes.LastIndex(bytes.ToLower(html), []byte("</body>"))
+	if idx < 0 {
+		return append(html, []byte(script)...)
+	}
+
+	r := make([]byte, 0, len(html)+len(script))
+	r = append(r, html[:idx]...)
+	r = append(r, script...)
+	return append(r, html[idx:]...)
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectingWriter(t *testing.T) {
+	const page = "<html><body>Hello</body></html>"
+	serve := func(method string, contentType string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/", nil)
+		iw := newInjectingWriter(rec, req)
+		iw.Header().Set("Content-Type", contentType)
+		http.ServeContent(iw, req, "index.html", time.Time{}, strings.NewReader(page))
+		assert.NoError(t, iw.Close())
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "text/html; charset=utf-8")
+	expected := "<html><body>Hello" + liveReloadScript + "</body></html>"
+	assert.Equal(t, expected, rec.Body.String())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, strconv.Itoa(len(expected)), rec.Header().Get("Content-Length"))
+
+	rec = serve(http.MethodGet, "text/plain")
+	assert.Equal(t, page, rec.Body.String())
+
+	rec = serve(http.MethodHead, "text/html; charset=utf-8")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, strconv.Itoa(len(page)), rec.Header().Get("Content-Length"))
+}
+
+func TestInjectScript(t *testing.T) {
+	assert.Equal(t, "<p>A</p><s>", string(injectScript([]byte("<p>A</p>"), "<s>")))
+	assert.Equal(t, "<BODY>A<s></BODY>", string(injectScript([]byte("<BODY>A</BODY>"), "<s>")))
+}
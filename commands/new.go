@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/roblillack/tack/core"
+)
+
+const newUsage = `usage: tack new site <dir>
+       tack new page <path> [--template <name>] [--position <n>]
+       tack new post <parent> <title>`
+
+// nonSlugCharacters matches everything but letters and digits. Non-ASCII
+// letters are kept, so that “Über uns” becomes “über-uns”.
+var nonSlugCharacters = regexp.MustCompile(`[^\p{L}\p{M}\p{N}]+`)
+
+const siteTemplate = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>{{name}} – {{title}}</title>
+    <link rel="stylesheet" href="{{base_path}}/style.css">
+  </head>
+  <body>
+    <nav>
+      {{#navigation}}
+      <a href="{{permalink}}"{{#current}} class="current"{{/current}}>{{name}}</a>
+      {{/navigation}}
+    </nav>
+
+    <h1>{{name}}</h1>
+    {{#date}}<p class="date">{{.}}</p>{{/date}}
+
+    {{{body}}}
+
+    {{#posts}}
+    <h2><a href="{{permalink}}">{{name}}</a></h2>
+    <p class="date">{{date}}</p>
+    {{/posts}}
+  </body>
+</html>
+`
+
+const siteStylesheet = `body {
+  font-family: sans-serif;
+  margin: 5rem 10%;
+  line-height: 1.5;
+}
+`
+
+// newOptions are the flags of the new verb.
+type newOptions struct {
+	template string
+	position int
+}
+
+func init() {
+	Register(Command{
+		Name:        "new",
+		Description: "Creates a new site, page, or post",
+		Usage:       newUsage,
+		Setup: func(flags *flag.FlagSet) Executor {
+			opts := &newOptions{}
+			flags.StringVar(&opts.template, "template", "", "Template to use for a new page")
+			flags.IntVar(&opts.position, "position", 0, "Position of a new page in the menu (makes the page an ordered one)")
+			return func(args ...string) error {
+				return create(opts, args...)
+			}
+		},
+	})
+}
+
+// New creates the skeleton of a new site, page, or post using the correct
+// directory naming conventions. The arguments may contain the flags of the
+// new verb.
+func New(args ...string) error {
+	return runCommand("new", args)
+}
+
+func create(opts *newOptions, args ...string) error {
+	if len(args) < 1 {
+		return errors.New(newUsage)
+	}
+
+	switch args[0] {
+	case "site":
+		if len(args) != 2 {
+			return errors.New(newUsage)
+		}
+		return newSite(args[1])
+	case "page":
+		return newPage(opts, args[1:]...)
+	case "post":
+		if len(args) != 3 {
+			return errors.New(newUsage)
+		}
+		return newPost(args[1], args[2])
+	}
+
+	return fmt.Errorf("unknown type '%s'\n%s", args[0], newUsage)
+}
+
+func newSite(dir string) (err error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("directory is not empty: %s", dir)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	name := strings.Replace(strings.Title(filepath.Base(abs)), "-", " ", -1)
+	files := map[string]string{
+		filepath.Join(core.ContentDir, "0.index", "body.md"): fmt.Sprintf("---\nname: Home\n---\n\n# Welcome to %s!\n\nThis page was created using `tack new site`.\n", name),
+		filepath.Join(core.TemplateDir, "default.mustache"):  siteTemplate,
+		filepath.Join(core.AssetDir, "style.css"):            siteStylesheet,
+		"site.yaml": fmt.Sprintf("title: %s\n", name),
+	}
+
+	// the directory is empty (or does not exist), so everything in it has
+	// been created here
+	existed := core.DirExists(dir)
+	defer func() {
+		if err == nil {
+			return
+		}
+		if !existed {
+			os.RemoveAll(dir)
+			return
+		}
+		entries, _ := os.ReadDir(dir)
+		for _, i := range entries {
+			os.RemoveAll(filepath.Join(dir, i.Name()))
+		}
+	}()
+
+	for _, i := range []string{filepath.Join(core.ContentDir, "0.index"), core.TemplateDir, core.AssetDir} {
+		if err := os.MkdirAll(filepath.Join(dir, i), 0755); err != nil {
+			return err
+		}
+	}
+
+	for fn, content := range files {
+		if err := createFile(filepath.Join(dir, fn), content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newPage(opts *newOptions, args ...string) error {
+	if len(args) != 1 {
+		return errors.New(newUsage)
+	}
+
+	parent, slug := path.Split(strings.Trim(args[0], "/"))
+	slug = slugify(slug)
+	if slug == "" {
+		return fmt.Errorf("invalid page path: %s", args[0])
+	}
+	dirname := slug
+	if opts.position > 0 {
+		dirname = fmt.Sprintf("%d.%s", opts.position, slug)
+	}
+
+	tacker, err := newTackerWithArgs()
+	if err != nil {
+		return err
+	}
+
+	dir, err := newPageDir(tacker, parent, slug, dirname)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{"body.md": "Content goes here.\n"}
+	if opts.template != "" {
+		files[opts.template+".yaml"] = "# Page variables go here, e.g.:\n# name: My page\n"
+	}
+
+	return createFiles(dir, files)
+}
+
+func newPost(parent string, title string) error {
+	slug := slugify(title)
+	if slug == "" {
+		return fmt.Errorf("invalid post title: %s", title)
+	}
+
+	tacker, err := newTackerWithArgs()
+	if err != nil {
+		return err
+	}
+
+	// dated in the site's time zone, so that the post is not scheduled
+	date := time.Now().In(tacker.Location()).Format("2006-01-02")
+	dir, err := newPageDir(tacker, parent, slug, date+"."+slug)
+	if err != nil {
+		return err
+	}
+
+	return createFiles(dir, map[string]string{
+		"body.md": fmt.Sprintf("---\nname: %q\n---\n\nContent goes here.\n", title),
+	})
+}
+
+// newPageDir creates the directory for a new page below the page found at the
+// given permalink.
+func newPageDir(tacker *core.Tacker, parentPermalink string, slug string, dirname string) (string, error) {
+	parentPermalink = "/" + strings.Trim(parentPermalink, "/")
+	parentDir := ""
+	if parentPermalink == "/" {
+		parentDir = tacker.Dir(core.ContentDir)
+	}
+	for _, i := range tacker.Pages {
+		if i.DiskPath == "" {
+			continue
+		}
+		if i.Permalink() == path.Join(parentPermalink, slug) {
+			return "", fmt.Errorf("page already exists: %s", i.DiskPath)
+		}
+		if i.Permalink() == parentPermalink && parentDir == "" {
+			parentDir = i.DiskPath
+		}
+	}
+	if parentDir == "" {
+		return "", fmt.Errorf("no page found at %s", parentPermalink)
+	}
+
+	// not using MkdirAll, so that the directory is known to be a new one
+	dir := filepath.Join(parentDir, dirname)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// createFiles creates the given files in the new directory dir. If this
+// fails, the directory is removed again.
+func createFiles(dir string, files map[string]string) error {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := createFile(filepath.Join(dir, name), files[name]); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createFile(filename string, content string) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s\n", filename)
+	return nil
+}
+
+func slugify(s string) string {
+	return strings.Trim(nonSlugCharacters.ReplaceAllLiteralString(strings.ToLower(s), "-"), "-")
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/roblillack/tack/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	for title, slug := range map[string]string{
+		"Hello World":          "hello-world",
+		"  --Tack 1.0 is out!": "tack-1-0-is-out",
+		"Über uns":             "über-uns",
+		"Café crème":           "café-crème",
+		"東京":                   "東京",
+		"???":                  "",
+	} {
+		assert.Equal(t, slug, slugify(title), title)
+	}
+}
+
+func TestNewSite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-site")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	chdir(t, dir)
+
+	assert.NoError(t, New("site", "."))
+	yaml, err := os.ReadFile(filepath.Join(dir, "site.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "title: My Site\n", string(yaml))
+
+	assert.NoError(t, New("page", "Über uns"))
+	assert.FileExists(t, filepath.Join(dir, core.ContentDir, "über-uns", "body.md"))
+
+	// the directory is removed again, if the page cannot be created
+	assert.Error(t, New("page", "broken", "--template", "missing/template"))
+	assert.NoDirExists(t, filepath.Join(dir, core.ContentDir, "broken"))
+
+	assert.NoError(t, New("page", "--position", "3", "Team", "--template", "people"))
+	assert.FileExists(t, filepath.Join(dir, core.ContentDir, "3.team", "people.yaml"))
+	// flags are not kept between runs
+	assert.NoError(t, New("page", "Imprint"))
+	assert.FileExists(t, filepath.Join(dir, core.ContentDir, "imprint", "body.md"))
+	assert.NoFileExists(t, filepath.Join(dir, core.ContentDir, "imprint", "people.yaml"))
+
+	assert.Error(t, New("site", "."))
+}
+
+func TestNewPostInSiteTimeZone(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-site")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	chdir(t, dir)
+	assert.NoError(t, New("site", "."))
+
+	// a local time zone far ahead of the site's default, UTC
+	kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Skip(err)
+	}
+	local := time.Local
+	time.Local = kiritimati
+	defer func() {
+		time.Local = local
+	}()
+
+	assert.NoError(t, New("post", "/", "Hello World"))
+	slug := time.Now().UTC().Format("2006-01-02") + ".hello-world"
+	assert.FileExists(t, filepath.Join(dir, core.ContentDir, slug, "body.md"))
+
+	tacker, err := core.NewTacker(dir)
+	assert.NoError(t, err)
+	assert.NotNil(t, tacker.FindPage("/hello-world"))
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/roblillack/tack/core"
+)
+
+type pageInfo struct {
+	Permalink  string   `json:"permalink"`
+	Kind       string   `json:"kind"`
+	Template   string   `json:"template"`
+	Date       string   `json:"date,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	TagIndex   bool     `json:"tag_index,omitempty"`
+	Navigation bool     `json:"navigation,omitempty"`
+	Draft      bool     `json:"draft,omitempty"`
+	Scheduled  bool     `json:"scheduled,omitempty"`
+	Parent     string   `json:"parent,omitempty"`
+	Depth      int      `json:"depth"`
+	DiskPath   string   `json:"disk_path,omitempty"`
+}
+
+func init() {
+	Register(Command{
+		Name:        "pages",
+		Description: "Prints the tree of all pages of the site",
+		Usage:       "usage: tack pages [--format text|json] [sitedir]",
+		Setup: func(flags *flag.FlagSet) Executor {
+			format := flags.String("format", "text", "Output format (text or json)")
+			return func(args ...string) error {
+				return pages(*format, args...)
+			}
+		},
+	})
+}
+
+// Pages prints the hierarchy of all pages as resolved by tack. The arguments
+// may contain the flags of the pages verb.
+func Pages(args ...string) error {
+	return runCommand("pages", args)
+}
+
+func pages(format string, args ...string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	tacker, err := newTackerWithArgs(args...)
+	if err != nil {
+		return err
+	}
+
+	list := pageTree(tacker)
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PERMALINK\tKIND\tTEMPLATE\tDATE\tTAGS\tPATH")
+	for _, i := range list {
+		kind := i.Kind
+		if i.Navigation {
+			kind += ", navigation"
+		}
+		if i.TagIndex {
+			kind += ", tag index"
+		}
+		if i.Draft {
+			kind += ", draft"
+		}
+		if i.Scheduled {
+			kind += ", scheduled"
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t%s\n", strings.Repeat("  ", i.Depth), i.Permalink, kind, i.Template, i.Date, strings.Join(i.Tags, ", "), i.DiskPath)
+	}
+
+	return w.Flush()
+}
+
+// pageTree returns information about all pages of the site, ordered
+// depth-first, with every page being followed by its descendants.
+func pageTree(tacker *core.Tacker) []pageInfo {
+	children := map[*core.Page][]*core.Page{}
+	for _, i := range tacker.Pages {
+		// further pages of posts are part of their first page
+		if i.FirstPage() != nil {
+			continue
+		}
+		children[i.Parent] = append(children[i.Parent], i)
+	}
+
+	navigation := map[*core.Page]bool{}
+	for _, i := range tacker.Navigation {
+		navigation[i] = true
+	}
+
+	list := []pageInfo{}
+	var walk func(parent *core.Page, depth int)
+	walk = func(parent *core.Page, depth int) {
+		pages := children[parent]
+		sort.Slice(pages, func(i, j int) bool {
+			return strings.Compare(pageSortKey(pages[i]), pageSortKey(pages[j])) < 0
+		})
+		for _, p := range pages {
+			info := pageInfo{
+				Permalink:  p.Permalink(),
+				Kind:       p.Kind(),
+				Template:   p.Template,
+				TagIndex:   p == tacker.TagIndex,
+				Navigation: navigation[p],
+				Draft:      p.Draft(),
+				Scheduled:  p.Scheduled(),
+				Depth:      depth,
+			}
+			if info.Template == "" {
+				info.Template = "default"
+			}
+			if p.Post() {
+				info.Date = p.Date.Format("2006-01-02")
+			}
+			if p.Parent != nil {
+				info.Parent = p.Parent.Permalink()
+			}
+			if p.DiskPath != "" {
+				if rel, err := filepath.Rel(tacker.BaseDir, p.DiskPath); err == nil {
+					info.DiskPath = rel
+				} else {
+					info.DiskPath = p.DiskPath
+				}
+			}
+			if tags, ok := p.Variables["tags"].([]interface{}); ok {
+				for _, t := range tags {
+					if s, ok := t.(string); ok && s != "" {
+						info.Tags = append(info.Tags, s)
+					}
+				}
+			}
+			list = append(list, info)
+			walk(p, depth+1)
+		}
+	}
+	walk(nil, 0)
+
+	return list
+}
+
+func pageSortKey(p *core.Page) string {
+	if p.DiskPath == "" {
+		return p.Slug
+	}
+
+	return filepath.Base(p.DiskPath)
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const renderUsage = "usage: tack render <permalink> [sitedir]"
+
+func init() {
+	Register(Command{
+		Name:        "render",
+		Description: "Renders a single page to stdout",
+		Usage:       renderUsage,
+		Fn:          Render,
+	})
+}
+
+// Render writes the HTML of the page with the given permalink to stdout
+// without touching the output directory.
+func Render(args ...string) error {
+	if len(args) < 1 {
+		return errors.New(renderUsage)
+	}
+
+	tacker, err := newTackerWithArgs(args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	page := tacker.FindPage(args[0])
+	if page == nil {
+		return fmt.Errorf("no page found at %s", args[0])
+	}
+
+	buf := &bytes.Buffer{}
+	if err := page.Render(buf); err != nil {
+		return explainError(err)
+	}
+
+	_, err = buf.WriteTo(os.Stdout)
+	return err
+}
@@ -1,10 +1,15 @@
 package commands
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"path/filepath"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,66 +22,229 @@ var noCacheHeaders = map[string]string{
 	"Pragma":        "no-cache",
 }
 
+// serveOptions are the flags of the serve verb.
+type serveOptions struct {
+	addr    string
+	tlsCert string
+	tlsKey  string
+	drafts  bool
+	future  bool
+}
+
 func init() {
-	RegisterCommand("serve", "Runs a minimal HTTP server", Serve)
+	Register(Command{
+		Name:        "serve",
+		Description: "Runs a minimal HTTP server",
+		Usage:       "usage: tack serve [--addr <host:port>] [--tls-cert <file> --tls-key <file>] [--drafts=false] [--future=false] [sitedir]",
+		Setup: func(flags *flag.FlagSet) Executor {
+			opts := &serveOptions{}
+			flags.StringVar(&opts.addr, "addr", "localhost:8080", "Address to listen on (host:port). If the port is in use, a free one is chosen")
+			flags.StringVar(&opts.tlsCert, "tls-cert", "", "Certificate file to serve the site using HTTPS")
+			flags.StringVar(&opts.tlsKey, "tls-key", "", "Private key file to serve the site using HTTPS")
+			// included by default, unless excluded using the global flags
+			flags.BoolVar(&opts.drafts, "drafts", globalBool("drafts", DraftsMode, true), "Include pages marked as drafts")
+			flags.BoolVar(&opts.future, "future", globalBool("future", FutureMode, true), "Include posts dated in the future")
+			return func(args ...string) error {
+				return serve(opts, args...)
+			}
+		},
+	})
 }
 
+// ServeError responds with an HTML page describing the error, which reloads
+// automatically once the site was re-tacked.
 func ServeError(w http.ResponseWriter, req *http.Request, err error) {
-	w.WriteHeader(500)
-	w.Write([]byte(fmt.Sprintf("Error: %s\n", err.Error())))
-	log.Printf("%s %s://%s%s%s -> ERROR: %s\n", req.Method, "http", req.Host, req.URL.Port(), req.RequestURI, err.Error())
+	for k, v := range noCacheHeaders {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := writeErrorPage(w, err); err != nil {
+		fmt.Fprintf(w, "Error: %s\n", err)
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	log.Printf("%s %s://%s%s%s -> ERROR: %s\n", req.Method, scheme, req.Host, req.URL.Port(), req.RequestURI, err.Error())
+}
+
+// siteState is the result of the last build, which is shared between the
+// watcher and the request handlers.
+type siteState struct {
+	mutex    sync.RWMutex
+	files    http.Handler
+	basePath string
+	err      error
+}
+
+// set records the result of a build. If the build failed, the files of the
+// last successful one are kept.
+func (s *siteState) set(files *core.MemorySink, basePath string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil {
+		s.files = http.FileServer(http.FS(files))
+	}
+	s.basePath = basePath
+	s.err = err
 }
 
+func (s *siteState) get() (http.Handler, string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.files, s.basePath, s.err
+}
+
+// Serve runs a web server previewing the site, which is re-tacked whenever
+// its files change. The arguments may contain the flags of the serve verb.
 func Serve(args ...string) error {
-	tacker, err := newTackerWithArgs(args...)
+	return runCommand("serve", args)
+}
+
+func serve(opts *serveOptions, args ...string) error {
+	if (opts.tlsCert == "") != (opts.tlsKey == "") {
+		return errors.New("both --tls-cert and --tls-key are needed to serve using HTTPS")
+	}
+
+	tacker, err := newTacker(opts.drafts, opts.future, args...)
 	if err != nil {
 		return err
 	}
-	if err := tacker.Tack(); err != nil {
+	checkpoint, err := tacker.Checkpoint()
+	if err != nil {
+		return err
+	}
+	// The site is kept in memory, so the output directory is never touched.
+	files := core.NewMemorySink()
+	err = tacker.TackTo(files)
+	if err != nil {
 		log.Println(err)
 	}
+	state := &siteState{}
+	state.set(files, tacker.BasePath(), err)
 	tacker.Logger = nil
 
-	var lastCheck time.Time
-	var checkpoint *core.Checkpoint
-	var mutex sync.Mutex
+	// listening first, so that the watcher is not started if this fails
+	listener, err := listen(opts.addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	reloads := newReloader()
+	templateDir := tacker.Dir(core.TemplateDir)
+	// The previous output keeps being served while re-tacking, as the new
+	// one is rendered into a separate sink.
+	go watchSite(tacker, checkpoint, pollInterval, func(changes []string) {
+		tackStart := time.Now()
+		var err error
+		if allBelow(templateDir, changes) {
+			tacker.InvalidateTemplates(changes...)
+		} else {
+			err = tacker.Reload()
+		}
+		files := core.NewMemorySink()
+		if err == nil {
+			err = tacker.TackTo(files)
+		}
+		if err != nil {
+			log.Println(err)
+		} else {
+			log.Printf("Changes detected. Re-tacked in %s.\n", time.Since(tackStart))
+		}
+		state.set(files, tacker.BasePath(), err)
+		reloads.Notify()
+	})
+
+	scheme := "http"
+	if opts.tlsCert != "" {
+		scheme = "https"
+	}
+	_, basePath, _ := state.get()
+	log.Printf("Serving from memory, listening on %s://%s%s/ …\n", scheme, displayAddr(listener.Addr()), basePath)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == liveReloadPath {
+			reloads.ServeHTTP(w, req)
+			return
+		}
 
-	htmlDir := filepath.Join(tacker.BaseDir, core.TargetDir)
-	log.Printf("Serving from %s, listening on port 8080 …\n", htmlDir)
-	server := http.FileServer(http.Dir(htmlDir))
-	return http.ListenAndServe(":8080", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		start := time.Now()
-		mutex.Lock()
-		defer mutex.Unlock()
-		if time.Since(lastCheck) >= 3*time.Second {
-			rebuild, newCheckpoint, err := tacker.HasChanges(checkpoint)
-			if err != nil {
-				ServeError(w, req, err)
-				return
-			}
-			if rebuild {
-				tackStart := time.Now()
-				if err := tacker.Reload(); err != nil {
-					ServeError(w, req, err)
-					return
-				}
-				if err := tacker.Tack(); err != nil {
-					ServeError(w, req, err)
-					return
-				}
-				if !lastCheck.IsZero() {
-					log.Printf("Changes detected. Re-tacked in %s.\n", time.Since(tackStart))
-				}
-				checkpoint = newCheckpoint
-			}
-			lastCheck = time.Now()
+		server, basePath, err := state.get()
+		if err != nil {
+			ServeError(w, req, err)
+			return
 		}
 
 		for k, v := range noCacheHeaders {
 			w.Header().Set(k, v)
 		}
 
-		server.ServeHTTP(w, req)
-		log.Printf("%s %s://%s%s%s (%s)\n", req.Method, "http", req.Host, req.URL.Port(), req.RequestURI, time.Since(start))
-	}))
+		iw := newInjectingWriter(w, req)
+		serveBelow(basePath, server, iw, req)
+		iw.Close()
+		log.Printf("%s %s://%s%s%s (%s)\n", req.Method, scheme, req.Host, req.URL.Port(), req.RequestURI, time.Since(start))
+	})}
+
+	if opts.tlsCert != "" {
+		return srv.ServeTLS(listener, opts.tlsCert, opts.tlsKey)
+	}
+	return srv.Serve(listener)
+}
+
+// listen opens a TCP listener on the given address. If the port is already in
+// use, a free port on the same host is chosen instead.
+func listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err == nil || !isAddrInUse(err) {
+		return l, err
+	}
+
+	host, port, splitErr := net.SplitHostPort(addr)
+	if splitErr != nil {
+		return nil, err
+	}
+	log.Printf("Port %s is in use, choosing a free one.\n", port)
+	return net.Listen("tcp", net.JoinHostPort(host, "0"))
+}
+
+// displayAddr returns the address of a listener in a form usable for URLs.
+func displayAddr(addr net.Addr) string {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.String()
+	}
+	if tcp.IP.IsUnspecified() || tcp.IP.IsLoopback() {
+		return net.JoinHostPort("localhost", strconv.Itoa(tcp.Port))
+	}
+
+	return tcp.String()
+}
+
+// serveBelow mounts the site at the base path configured using the `base_url`
+// site metadata variable. Requests outside of the base path are redirected to
+// it.
+func serveBelow(basePath string, handler http.Handler, w http.ResponseWriter, req *http.Request) {
+	if basePath == "" {
+		handler.ServeHTTP(w, req)
+		return
+	}
+
+	if req.URL.Path != basePath && !strings.HasPrefix(req.URL.Path, basePath+"/") {
+		http.Redirect(w, req, basePath+"/", http.StatusFound)
+		return
+	}
+
+	http.StripPrefix(basePath, handler).ServeHTTP(w, req)
+}
+
+// allBelow returns true if all of the given files are located below dir.
+func allBelow(dir string, files []string) bool {
+	for _, i := range files {
+		if !strings.HasPrefix(i, dir+string(os.PathSeparator)) {
+			return false
+		}
+	}
+
+	return true
 }
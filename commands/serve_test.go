@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"flag"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenChoosesFreePort(t *testing.T) {
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer used.Close()
+
+	l, err := listen(used.Addr().String())
+	assert.NoError(t, err)
+	defer l.Close()
+	assert.NotEqual(t, used.Addr().String(), l.Addr().String())
+
+	_, err = listen("127.0.0.1:invalid")
+	assert.Error(t, err)
+}
+
+func TestServeDefaultsToGlobalFlags(t *testing.T) {
+	defer func(cl *flag.FlagSet, drafts bool) {
+		flag.CommandLine = cl
+		DraftsMode = drafts
+	}(flag.CommandLine, DraftsMode)
+	flag.CommandLine = flag.NewFlagSet("tack", flag.ContinueOnError)
+	flag.CommandLine.BoolVar(&DraftsMode, "drafts", false, "")
+
+	defaults := func() (string, string) {
+		flags, _ := FindCommand("serve").setup()
+		return flags.Lookup("drafts").DefValue, flags.Lookup("future").DefValue
+	}
+
+	drafts, future := defaults()
+	assert.Equal(t, "true", drafts)
+	assert.Equal(t, "true", future)
+
+	// tack -drafts=false serve
+	assert.NoError(t, flag.CommandLine.Parse([]string{"-drafts=false", "serve"}))
+	drafts, future = defaults()
+	assert.Equal(t, "false", drafts)
+	assert.Equal(t, "true", future)
+}
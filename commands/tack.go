@@ -1,7 +1,12 @@
 package commands
 
 func init() {
-	RegisterCommand("tack", "Tacks up everything", Tack)
+	Register(Command{
+		Name:        "tack",
+		Description: "Tacks up everything",
+		Usage:       "usage: tack [tack] [sitedir]",
+		Fn:          Tack,
+	})
 }
 
 func Tack(args ...string) error {
@@ -10,5 +15,5 @@ func Tack(args ...string) error {
 		return err
 	}
 
-	return tacker.Tack()
+	return explainError(tacker.Tack())
 }
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/roblillack/tack/core"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const varsUsage = "usage: tack vars [--format yaml|json] <permalink> [sitedir]"
+
+func init() {
+	Register(Command{
+		Name:        "vars",
+		Description: "Prints the rendering context of a page",
+		Usage:       varsUsage,
+		Setup: func(flags *flag.FlagSet) Executor {
+			format := flags.String("format", "yaml", "Output format (yaml or json)")
+			return func(args ...string) error {
+				return vars(*format, args...)
+			}
+		},
+	})
+}
+
+// Vars prints all variables available to the template when rendering the
+// page with the given permalink, as well as the files user-defined variables
+// have been read from. The arguments may contain the flags of the vars verb.
+func Vars(args ...string) error {
+	return runCommand("vars", args)
+}
+
+func vars(format string, args ...string) error {
+	if format != "yaml" && format != "json" {
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	if len(args) < 1 {
+		return errors.New(varsUsage)
+	}
+
+	tacker, err := newTackerWithArgs(args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	page := tacker.FindPage(args[0])
+	if page == nil {
+		return fmt.Errorf("no page found at %s", args[0])
+	}
+
+	ctx := core.RenderContext(page)
+	sources := core.ContextSources(page, ctx)
+	for k, v := range sources {
+		if rel, err := filepath.Rel(tacker.BaseDir, v); err == nil {
+			sources[k] = rel
+		}
+	}
+
+	result := map[string]interface{}{
+		"permalink": page.Permalink(),
+		"template":  page.Template,
+		"sources":   sources,
+		"context":   ctx,
+	}
+	if page.Template == "" {
+		result["template"] = "default"
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jsonValue(result))
+	}
+
+	return yaml.NewEncoder(os.Stdout).Encode(result)
+}
+
+// jsonValue converts maps with non-string keys, as created when decoding
+// YAML, into maps that can be encoded as JSON.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		r := map[string]interface{}{}
+		for k, i := range val {
+			r[fmt.Sprint(k)] = jsonValue(i)
+		}
+		return r
+	case map[string]interface{}:
+		if val == nil {
+			return nil
+		}
+		r := map[string]interface{}{}
+		for k, i := range val {
+			r[k] = jsonValue(i)
+		}
+		return r
+	case []map[string]interface{}:
+		r := []interface{}{}
+		for _, i := range val {
+			r = append(r, jsonValue(i))
+		}
+		return r
+	case []interface{}:
+		r := []interface{}{}
+		for _, i := range val {
+			r = append(r, jsonValue(i))
+		}
+		return r
+	}
+
+	return v
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"log"
+	"time"
+
+	"github.com/roblillack/tack/core"
+)
+
+// pollInterval is the time between two checks for changed site files.
+const pollInterval = 500 * time.Millisecond
+
+// watchSite checks the site for changes every interval, starting with the
+// given checkpoint. Once changes have been detected, it waits until no more
+// files changed for a whole interval—so a burst of saves only results in a
+// single rebuild—and calls rebuild with the list of changed files.
+func watchSite(tacker *core.Tacker, checkpoint *core.Checkpoint, interval time.Duration, rebuild func(changes []string)) {
+	built := checkpoint
+	latest := checkpoint
+	for range time.Tick(interval) {
+		changed, now, err := tacker.HasChanges(latest)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if changed {
+			latest = now
+			continue
+		}
+		if latest != built {
+			rebuild(latest.Changes(built))
+			built = latest
+		}
+	}
+}
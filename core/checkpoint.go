@@ -1,8 +1,12 @@
 package core
 
 import (
+	"errors"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -35,29 +39,82 @@ func (c *Checkpoint) Equals(o *Checkpoint) bool {
 	return true
 }
 
-// Checkpoint stats all the files within the source directory and stores the
-// names and modification timestamps so we're able to compare this list with a
+// Changes returns the names of all files which have been added, removed, or
+// modified compared to the previous checkpoint. If no previous checkpoint is
+// given, all files are regarded as changed.
+func (c *Checkpoint) Changes(prev *Checkpoint) []string {
+	r := []string{}
+	if prev == nil {
+		for _, i := range c.files {
+			r = append(r, i.Name)
+		}
+		return r
+	}
+
+	before := map[string]time.Time{}
+	for _, i := range prev.files {
+		before[i.Name] = i.ModTime
+	}
+	for _, i := range c.files {
+		if t, ok := before[i.Name]; !ok || !t.Equal(i.ModTime) {
+			r = append(r, i.Name)
+		}
+		delete(before, i.Name)
+	}
+	for name := range before {
+		r = append(r, name)
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// Checkpoint stats all the files within the source directory (and all site
+// directories configured to be located outside of it) and stores the names
+// and modification timestamps so we're able to compare this list with a
 // future checkpoint without the need to set up file watchers.
 func (t *Tacker) Checkpoint() (*Checkpoint, error) {
-	outputDir := filepath.Join(t.BaseDir, TargetDir)
+	outputDir := t.Dir(TargetDir)
+	stateFile := filepath.Join(t.BaseDir, StateFile)
 	checkpoint := &Checkpoint{}
-	if err := filepath.Walk(t.BaseDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
+
+	roots := []string{t.BaseDir}
+	for _, i := range []string{ContentDir, TemplateDir, AssetDir} {
+		dir := t.Dir(i)
+		if rel, err := filepath.Rel(t.BaseDir, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			roots = append(roots, dir)
 		}
-		if path == t.BaseDir {
+	}
+
+	for _, root := range roots {
+		if err := t.source().WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root && root != t.BaseDir && errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if path == root {
+				return nil
+			}
+			if path == outputDir || path == StagingDir(outputDir) || path == backupDir(outputDir) {
+				return filepath.SkipDir
+			}
+			if path == stateFile {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			checkpoint.files = append(checkpoint.files, fileInfo{
+				Name:    path,
+				ModTime: info.ModTime(),
+			})
 			return nil
+		}); err != nil {
+			return nil, err
 		}
-		if path == outputDir {
-			return filepath.SkipDir
-		}
-		checkpoint.files = append(checkpoint.files, fileInfo{
-			Name:    path,
-			ModTime: info.ModTime(),
-		})
-		return nil
-	}); err != nil {
-		return nil, err
 	}
 
 	return checkpoint, nil
@@ -35,9 +35,11 @@ func TestCheckpoints(t *testing.T) {
 		assert.NoError(t, err)
 		assert.False(t, changes)
 		assert.NoError(t, os.WriteFile(filepath.Join(site, "temp.yaml"), []byte{}, 0644))
-		changes, _, err = tacker.HasChanges(checkpoint)
+		changes, newCheckpoint, err := tacker.HasChanges(checkpoint)
 		assert.NoError(t, err)
 		assert.True(t, changes)
+		assert.Equal(t, []string{filepath.Join(site, "temp.yaml")}, newCheckpoint.Changes(checkpoint))
+		assert.Empty(t, newCheckpoint.Changes(newCheckpoint))
 		assert.NoError(t, os.Remove(filepath.Join(site, "temp.yaml")))
 	}
 }
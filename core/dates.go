@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultDateFormat is the layout of the `date` variable of posts, unless
+// configured differently using the `date_format` setting.
+const DefaultDateFormat = "2006-01-02"
+
+// DefaultLocale is the locale used to format dates, unless configured
+// differently using the `locale` setting.
+const DefaultLocale = "en"
+
+// locale contains the translated names used when formatting dates, as well as
+// the layouts of the `date_long` and `date_short` variables.
+type locale struct {
+	months        [12]string
+	shortMonths   [12]string
+	weekdays      [7]string
+	shortWeekdays [7]string
+	long          string
+	short         string
+}
+
+var locales = map[string]*locale{
+	"de": {
+		months:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths:   [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortWeekdays: [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		long:          "2. January 2006",
+		short:         "2. Jan",
+	},
+	"en": {
+		months:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		shortWeekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		long:          "January 2, 2006",
+		short:         "Jan 2",
+	},
+	"es": {
+		months:        [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths:   [12]string{"ene.", "feb.", "mar.", "abr.", "may.", "jun.", "jul.", "ago.", "sept.", "oct.", "nov.", "dic."},
+		weekdays:      [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortWeekdays: [7]string{"dom.", "lun.", "mar.", "mié.", "jue.", "vie.", "sáb."},
+		long:          "2 de January de 2006",
+		short:         "2 Jan",
+	},
+	"fr": {
+		months:        [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths:   [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:      [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortWeekdays: [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		long:          "2 January 2006",
+		short:         "2 Jan",
+	},
+	"it": {
+		months:        [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths:   [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		weekdays:      [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortWeekdays: [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		long:          "2 January 2006",
+		short:         "2 Jan",
+	},
+	"nl": {
+		months:        [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths:   [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		weekdays:      [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortWeekdays: [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		long:          "2 January 2006",
+		short:         "2 Jan",
+	},
+}
+
+// Locales returns the names of all locales dates can be formatted in.
+func Locales() []string {
+	r := []string{}
+	for name := range locales {
+		r = append(r, name)
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// findLocale returns the locale of the given name. Regional variants, like
+// “de-AT” or “fr_CA”, use the locale of the respective language.
+func findLocale(name string) (*locale, error) {
+	lang := strings.ToLower(name)
+	if idx := strings.IndexAny(lang, "-_"); idx >= 0 {
+		lang = lang[:idx]
+	}
+
+	if l, ok := locales[lang]; ok {
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("unknown locale: %s (supported: %s)", name, strings.Join(Locales(), ", "))
+}
+
+// format formats the time like time.Format does, but uses the locale's names
+// for the month and weekday tokens (“January”, “Jan”, “Monday”, and “Mon”).
+func (l *locale) format(t time.Time, layout string) string {
+	sb := strings.Builder{}
+	start := 0
+	for i := 0; i < len(layout); i++ {
+		name, n := "", 0
+		switch rest := layout[i:]; {
+		case strings.HasPrefix(rest, "January"):
+			name, n = l.months[t.Month()-1], 7
+		case strings.HasPrefix(rest, "Jan") && !startsWithLowerCase(rest[3:]):
+			name, n = l.shortMonths[t.Month()-1], 3
+		case strings.HasPrefix(rest, "Monday"):
+			name, n = l.weekdays[t.Weekday()], 6
+		case strings.HasPrefix(rest, "Mon") && !startsWithLowerCase(rest[3:]):
+			name, n = l.shortWeekdays[t.Weekday()], 3
+		default:
+			continue
+		}
+
+		sb.WriteString(t.Format(layout[start:i]))
+		sb.WriteString(name)
+		i += n - 1
+		start = i + 1
+	}
+	sb.WriteString(t.Format(layout[start:]))
+
+	return sb.String()
+}
+
+// startsWithLowerCase mirrors the check time.Format uses to tell the “Jan”
+// and “Mon” tokens apart from words like “Monthly”.
+func startsWithLowerCase(s string) bool {
+	return len(s) > 0 && s[0] >= 'a' && s[0] <= 'z'
+}
+
+// setting returns the page setting of the given name, falling back to the
+// site metadata variable of the same name.
+func (p *Page) setting(name string) string {
+	if s, ok := p.Variables[name].(string); ok && s != "" {
+		return s
+	}
+	if s, ok := p.Tacker.Metadata[name].(string); ok && s != "" {
+		return s
+	}
+
+	return ""
+}
+
+// dateFormat returns the layout of the page's `date` variable.
+func (p *Page) dateFormat() string {
+	if s := p.setting("date_format"); s != "" {
+		return s
+	}
+
+	return DefaultDateFormat
+}
+
+// locale returns the locale the page's dates are formatted in.
+func (p *Page) locale() (*locale, error) {
+	if s := p.setting("locale"); s != "" {
+		return findLocale(s)
+	}
+
+	return locales[DefaultLocale], nil
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var errorPosition = regexp.MustCompile(`line (\d+)(?:, column (\d+))?`)
+
+// SourceError is an error found in one of the source files of the site, like
+// a metadata file containing invalid YAML or a template with an unclosed tag.
+// If available, the position of the error is parsed from the message of the
+// original error.
+type SourceError struct {
+	Filename string
+	// Line and Column are 1-based and 0 if unknown.
+	Line   int
+	Column int
+	// Permalink is the permalink of the page being processed, if any.
+	Permalink string
+	Err       error
+}
+
+func newSourceError(filename string, err error) *SourceError {
+	e := &SourceError{Filename: filename, Err: err}
+	if m := errorPosition.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Column, _ = strconv.Atoi(m[2])
+	}
+
+	return e
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Filename, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// withPermalink records the permalink of the page being processed for all
+// source errors which do not have one yet.
+func withPermalink(err error, permalink string) error {
+	var se *SourceError
+	if errors.As(err, &se) && se.Permalink == "" {
+		se.Permalink = permalink
+	}
+
+	return err
+}
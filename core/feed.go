@@ -0,0 +1,281 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Feed formats which can be configured using the `feed` page setting.
+const (
+	FeedAtom = "atom"
+	FeedRSS  = "rss"
+	FeedBoth = "both"
+)
+
+// FeedFile is the name of the file the feed of a page is written to. If both
+// formats are requested, the RSS feed is written to RSSFeedFile.
+const FeedFile = "feed.xml"
+const RSSFeedFile = "rss.xml"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string    `xml:"title"`
+	ID        string    `xml:"id"`
+	Link      atomLink  `xml:"link"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+	Summary   *atomText `xml:"summary,omitempty"`
+	Content   *atomText `xml:"content,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+// FeedFormats returns the feed formats requested for the page using the
+// `feed` page setting.
+func (p *Page) FeedFormats() ([]string, error) {
+	v, ok := p.Variables["feed"]
+	if !ok || v == nil || v == false {
+		return nil, nil
+	}
+
+	switch fmt.Sprint(v) {
+	case FeedAtom:
+		return []string{FeedAtom}, nil
+	case FeedRSS:
+		return []string{FeedRSS}, nil
+	case FeedBoth:
+		return []string{FeedAtom, FeedRSS}, nil
+	}
+
+	return nil, fmt.Errorf("invalid feed format for '%s': %v (use %s, %s, or %s)", p.Permalink(), v, FeedAtom, FeedRSS, FeedBoth)
+}
+
+// FeedPermalink returns the permalink of the page's (primary) feed, or an
+// empty string if the page does not have a feed.
+func (p *Page) FeedPermalink() string {
+	if p.first != nil {
+		return p.first.FeedPermalink()
+	}
+	if formats, err := p.FeedFormats(); err != nil || len(formats) == 0 || len(p.Posts) == 0 {
+		return ""
+	}
+
+	return strings.TrimSuffix(p.Permalink(), "/") + "/" + FeedFile
+}
+
+// feedFiles returns the names of the feed files for the given formats.
+func feedFiles(formats []string) map[string]string {
+	if len(formats) == 1 {
+		return map[string]string{formats[0]: FeedFile}
+	}
+
+	return map[string]string{FeedAtom: FeedFile, FeedRSS: RSSFeedFile}
+}
+
+// generateFeeds writes the feeds requested for the page as part of the given
+// build. Pages without posts do not get a feed. If the posts are paginated,
+// the feed is generated for the first page only.
+func (p *Page) generateFeeds(b *build, relDir string) error {
+	if p.first != nil {
+		return nil
+	}
+
+	formats, err := p.FeedFormats()
+	if err != nil || len(formats) == 0 || len(p.Posts) == 0 {
+		return err
+	}
+
+	if u, err := p.Tacker.BaseURL(); err != nil || u == nil {
+		return fmt.Errorf("unable to generate feed for '%s': base_url needs to be set in the site metadata", p.Permalink())
+	}
+
+	posts := make([]*Page, len(p.Posts))
+	copy(posts, p.Posts)
+	sortPosts(posts)
+	posts = limitPageList(posts, p, "feed_limit")
+
+	for format, filename := range feedFiles(formats) {
+		buf := &bytes.Buffer{}
+		buf.WriteString(xml.Header)
+		enc := xml.NewEncoder(buf)
+		enc.Indent("", "  ")
+		var feed interface{}
+		if format == FeedAtom {
+			feed = p.atomFeed(filename, posts)
+		} else {
+			feed = p.rssFeed(posts)
+		}
+		if err := enc.Encode(feed); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+
+		rel := filepath.Join(relDir, filename)
+		if b.upToDate(rel, fingerprint(buf.String())) {
+			continue
+		}
+		if err := b.writeFile(rel, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Page) feedTitle() string {
+	if s, ok := p.Variables["title"].(string); ok && s != "" {
+		return s
+	}
+	if s := p.Tacker.stringSetting("title"); s != "" {
+		if p.Root() {
+			return s
+		}
+		return p.Name + " – " + s
+	}
+
+	return p.Name
+}
+
+// author returns the author of the page's feed. As Atom feeds need to have
+// one, the site title (or the page's name) is used if it is not set.
+func (p *Page) author() string {
+	if s, ok := p.Variables["author"].(string); ok && s != "" {
+		return s
+	}
+	if s := p.Tacker.stringSetting("author"); s != "" {
+		return s
+	}
+	if s := p.Tacker.stringSetting("title"); s != "" {
+		return s
+	}
+
+	return p.Name
+}
+
+func (p *Page) feedContent() string {
+	s, ok := p.Variables["body"].(string)
+	if !ok || s == "" {
+		return ""
+	}
+	base, err := url.Parse(strings.TrimSuffix(p.Tacker.URL(p.Permalink()), "/") + "/")
+	if err != nil {
+		return s
+	}
+
+	return resolveLinks(s, base, true)
+}
+
+func (p *Page) atomFeed(filename string, posts []*Page) *atomFeed {
+	url := p.Tacker.URL(p.Permalink())
+	feed := &atomFeed{
+		Title: p.feedTitle(),
+		ID:    url,
+		Links: []atomLink{
+			{Href: url},
+			{Href: p.Tacker.URL(strings.TrimSuffix(p.Permalink(), "/") + "/" + filename), Rel: "self"},
+		},
+		Updated: posts[0].Date.Format(time.RFC3339),
+		Author:  atomAuthor{Name: p.author()},
+	}
+
+	for _, i := range posts {
+		link := p.Tacker.URL(i.Permalink())
+		entry := atomEntry{
+			Title:     fmt.Sprint(PageValues(i, nil)["name"]),
+			ID:        link,
+			Link:      atomLink{Href: link},
+			Published: i.Date.Format(time.RFC3339),
+			Updated:   i.Date.Format(time.RFC3339),
+		}
+		if s, ok := i.Variables["description"].(string); ok && s != "" {
+			entry.Summary = &atomText{Type: "text", Body: s}
+		}
+		if s := i.feedContent(); s != "" {
+			entry.Content = &atomText{Type: "html", Body: s}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func (p *Page) rssFeed(posts []*Page) *rssFeed {
+	feed := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         p.feedTitle(),
+			Link:          p.Tacker.URL(p.Permalink()),
+			Description:   p.feedTitle(),
+			LastBuildDate: posts[0].Date.Format(time.RFC1123Z),
+		},
+	}
+	if s, ok := p.Variables["description"].(string); ok && s != "" {
+		feed.Channel.Description = s
+	}
+
+	for _, i := range posts {
+		link := p.Tacker.URL(i.Permalink())
+		item := rssItem{
+			Title:   fmt.Sprint(PageValues(i, nil)["name"]),
+			Link:    link,
+			GUID:    link,
+			PubDate: i.Date.Format(time.RFC1123Z),
+		}
+		if s := i.feedContent(); s != "" {
+			item.Description = s
+		} else if s, ok := i.Variables["description"].(string); ok {
+			item.Description = s
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return feed
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -12,6 +13,10 @@ import (
 // FindFiles returns a slice containing the absolute path of _all_ regular files below
 // the given directory.
 func FindFiles(dir string) ([]string, error) {
+	return source{}.findFiles(dir)
+}
+
+func (s source) findFiles(dir string) ([]string, error) {
 	result := []string{}
 	walk := func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
@@ -25,10 +30,14 @@ func FindFiles(dir string) ([]string, error) {
 		return nil
 	}
 
-	return result, filepath.WalkDir(dir, walk)
+	return result, s.WalkDir(dir, walk)
 }
 
 func FindDirsWithFiles(dir string, extensions ...string) ([]string, error) {
+	return source{}.findDirsWithFiles(dir, extensions...)
+}
+
+func (s source) findDirsWithFiles(dir string, extensions ...string) ([]string, error) {
 	result := []string{}
 	walk := func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
@@ -45,7 +54,7 @@ func FindDirsWithFiles(dir string, extensions ...string) ([]string, error) {
 		}
 
 		for _, ext := range extensions {
-			m, err := filepath.Glob(filepath.Join(path, "*."+ext))
+			m, err := s.Glob(filepath.Join(path, "*."+ext))
 			if err != nil {
 				return err
 			}
@@ -58,7 +67,7 @@ func FindDirsWithFiles(dir string, extensions ...string) ([]string, error) {
 		return nil
 	}
 
-	return result, filepath.WalkDir(dir, walk)
+	return result, s.WalkDir(dir, walk)
 }
 
 func CopyFile(src, dst string) error {
@@ -93,11 +102,14 @@ func BasenameWithoutExtension(path string) string {
 }
 
 func DirExists(path string) bool {
-	s, err := os.Stat(path)
-	return err == nil && s.IsDir()
+	return source{}.dirExists(path)
 }
 
 func FirstFileWithExtension(dir string, basename string, extensions ...string) string {
+	return source{}.firstFileWithExtension(dir, basename, extensions...)
+}
+
+func (src source) firstFileWithExtension(dir string, basename string, extensions ...string) string {
 	if extensions == nil {
 		extensions = []string{""}
 	}
@@ -107,7 +119,7 @@ func FirstFileWithExtension(dir string, basename string, extensions ...string) s
 		if ext != "" {
 			fn = fn + "." + ext
 		}
-		s, err := os.Stat(fn)
+		s, err := src.Stat(fn)
 		if err == nil && !s.IsDir() {
 			return fn
 		}
@@ -115,3 +127,76 @@ func FirstFileWithExtension(dir string, basename string, extensions ...string) s
 
 	return ""
 }
+
+// StagingDir returns the path of the directory, that the contents of the
+// given directory are prepared in before replacing it.
+func StagingDir(dir string) string {
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".tmp")
+}
+
+func backupDir(dir string) string {
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".old")
+}
+
+// LinkTree re-creates the directory structure below src in dst and hard-links
+// all regular files. If hard-linking is not possible, files are copied
+// instead while keeping their modification timestamps.
+func LinkTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, strings.TrimPrefix(path, src))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if err := os.Link(path, target); err == nil {
+			return nil
+		}
+		if err := CopyFile(path, target); err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+// ReplaceDir replaces the directory dst with src. If dst exists, it is moved
+// out of the way first and only removed after src has been renamed
+// successfully. As this takes two renames, dst does not exist in between, so
+// the replacement is not atomic.
+func ReplaceDir(src, dst string) error {
+	backup := backupDir(dst)
+	if err := os.RemoveAll(backup); err != nil {
+		return err
+	}
+
+	if err := os.Rename(dst, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		if restoreErr := os.Rename(backup, dst); restoreErr != nil && !errors.Is(restoreErr, os.ErrNotExist) {
+			return fmt.Errorf("%s (unable to restore %s: %s)", err, dst, restoreErr)
+		}
+		return err
+	}
+
+	return os.RemoveAll(backup)
+}
+
+// isWithin returns true if the path is equal to dir or located inside of it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -3,11 +3,13 @@ package core
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +20,11 @@ import (
 )
 
 var enumerationRegex = regexp.MustCompile(`^[0-9]+\.\s*`)
-var dateRegex = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2})[\.\-]\s*`)
+var dateRegex = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}(?:T[0-9]{2}-[0-9]{2}(?:-[0-9]{2})?)?)[\.\-]\s*`)
+
+// dateLayouts are the formats of the dates (and times) accepted as part of
+// the directory names of posts.
+var dateLayouts = []string{"2006-01-02", "2006-01-02T15-04", "2006-01-02T15-04-05"}
 
 // Page is the main structure holding page content. Some of the fields are
 // only available after the page has been initialized using Init().
@@ -39,8 +45,20 @@ type Page struct {
 	Posts         []*Page
 	Assets        map[string]struct{}
 	Variables     map[string]interface{}
-	Template      string
-	addTagPages   bool
+	// Sources maps the names of all Variables to the files they were read
+	// from.
+	Sources     map[string]string
+	Template    string
+	addTagPages bool
+	// tagNames are the names of all tags the page was tagged with.
+	tagNames []string
+	// pageNumber is the number of the page of posts shown, if the page's
+	// posts are paginated. For all but the first page, first refers to the
+	// page being paginated.
+	pageNumber int
+	first      *Page
+	// chronology orders the page's Posts to look up their neighbours.
+	chronology *chronology
 }
 
 // NewPage creates a new page structure for the specified Tacker
@@ -48,7 +66,7 @@ type Page struct {
 // will take place here just yet.
 func NewPage(tacker *Tacker, realPath string) *Page {
 	fn := filepath.Base(realPath)
-	if realPath == filepath.Join(tacker.BaseDir, ContentDir) {
+	if realPath == tacker.Dir(ContentDir) {
 		fn = "index"
 	}
 
@@ -63,10 +81,13 @@ func NewPage(tacker *Tacker, realPath string) *Page {
 		page.Floating = false
 		page.Slug = enumerationRegex.ReplaceAllLiteralString(fn, "")
 	} else if m := dateRegex.FindStringSubmatch(fn); len(m) == 2 {
-		if d, err := time.Parse("2006-01-02", m[1]); err == nil {
-			page.Slug = dateRegex.ReplaceAllLiteralString(fn, "")
-			page.Date = d
-			page.Floating = false
+		for _, layout := range dateLayouts {
+			if d, err := time.ParseInLocation(layout, m[1], tacker.Location()); err == nil {
+				page.Slug = dateRegex.ReplaceAllLiteralString(fn, "")
+				page.Date = d
+				page.Floating = false
+				break
+			}
 		}
 	}
 
@@ -75,17 +96,40 @@ func NewPage(tacker *Tacker, realPath string) *Page {
 	return page
 }
 
+// timeLayouts are the formats accepted for points in time given in metadata.
+// All of them but RFC 3339 are interpreted in the site's time zone.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseTime parses a point in time given in metadata.
+func parseTime(v interface{}, loc *time.Location) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+
+	s := fmt.Sprint(v)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time: %s", s)
+}
+
 // Root determines if the current page is the root page of the website being
 // tacked. The root page might be stored in the top-level content directory
 // or a directory with the slug "index" just below the top level.
 func (p *Page) Root() bool {
-	return p.DiskPath == filepath.Join(p.Tacker.BaseDir, ContentDir) ||
-		p.Slug == "index" && filepath.Dir(p.DiskPath) == filepath.Join(p.Tacker.BaseDir, ContentDir)
+	return p.DiskPath == p.Tacker.Dir(ContentDir) ||
+		p.Slug == "index" && filepath.Dir(p.DiskPath) == p.Tacker.Dir(ContentDir)
 }
 
 // Permalink return an absolute path to the current page based on its and it's
 // ancestor pages' slugs. The Page must be Init()ed prior to calling this.
 func (p *Page) Permalink() string {
+	if p.first != nil {
+		return p.pagePermalink(p.pageNumber)
+	}
 	if p.Parent == nil {
 		if p.Root() {
 			return "/"
@@ -100,6 +144,9 @@ func (p *Page) Permalink() string {
 // this page's HTML and further assets. The Page must be Init()ed prior to
 // calling this.
 func (p *Page) TargetDir() []string {
+	if p.first != nil {
+		return append(p.first.TargetDir(), PaginationSlug, strconv.Itoa(p.pageNumber))
+	}
 	if p.Parent == nil {
 		if p.Root() {
 			return []string{}
@@ -137,53 +184,73 @@ func (p *Page) Siblings() []*Page {
 	return r
 }
 
+// adjacentSiblings returns the ordered pages right before and after the
+// current one among its siblings.
+func (p *Page) adjacentSiblings() (previous, next *Page) {
+	self := p
+	if p.first != nil {
+		self = p.first
+	}
+
+	for idx, i := range p.SiblingsAndMe {
+		if i != self {
+			continue
+		}
+		if idx > 0 {
+			previous = p.SiblingsAndMe[idx-1]
+		}
+		if idx+1 < len(p.SiblingsAndMe) {
+			next = p.SiblingsAndMe[idx+1]
+		}
+	}
+
+	return previous, next
+}
+
+// siblingChronology returns the chronology of the posts of the page's
+// parent, or of all top-level posts if the page does not have a parent.
+func (p *Page) siblingChronology() *chronology {
+	if p.Parent != nil {
+		return p.Parent.chronology
+	}
+
+	return p.Tacker.chronology
+}
+
 // Post returns `true` if the current page has a post date defined as
 // part of the content directory name.
 func (p *Page) Post() bool {
 	return !p.Date.IsZero()
 }
 
+// Kind returns the type of the page, which is one of “root”, “ordered”,
+// “floating”, “post”, or “tag” for generated tag pages.
+func (p *Page) Kind() string {
+	switch {
+	case p.DiskPath == "" && p.Parent != nil && p.Parent == p.Tacker.TagIndex:
+		return "tag"
+	case p.Root():
+		return "root"
+	case p.Post():
+		return "post"
+	case p.Floating:
+		return "floating"
+	}
+
+	return "ordered"
+}
+
 // Init initializes the page content, by reading the content and metadata from
 // the disk, resolving the used template and creating the necessary structures
 // to reference other pages from this one.
 func (p *Page) Init() error {
-	parent := filepath.Dir(p.DiskPath)
-	siblingsAndMe := []*Page{}
-	children := []*Page{}
-	posts := []*Page{}
-
-	for _, i := range p.Tacker.Pages {
-		if i.DiskPath == parent {
-			p.Parent = i
-		}
-		if filepath.Dir(i.DiskPath) == parent && !i.Floating && !i.Post() {
-			siblingsAndMe = append(siblingsAndMe, i)
-		}
-		if filepath.Dir(i.DiskPath) == p.DiskPath {
-			if !i.Post() && !i.Floating {
-				children = append(children, i)
-			} else if i.Post() {
-				posts = append(posts, i)
-			}
-		}
-	}
-
-	sort.Slice(siblingsAndMe, func(i, j int) bool {
-		return strings.Compare(filepath.Base(siblingsAndMe[i].DiskPath), filepath.Base(siblingsAndMe[j].DiskPath)) == -1
-	})
-	p.SiblingsAndMe = siblingsAndMe
-	sort.Slice(children, func(i, j int) bool {
-		return strings.Compare(filepath.Base(children[i].DiskPath), filepath.Base(children[j].DiskPath)) == -1
-	})
-	p.Children = children
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date.After(posts[j].Date)
-	})
-	p.Posts = posts
+	p.link()
 	p.Assets = map[string]struct{}{}
 	p.Variables = map[string]interface{}{}
+	p.Sources = map[string]string{}
+	p.tagNames = nil
 
-	allFiles, err := FindFiles(p.DiskPath)
+	allFiles, err := p.Tacker.source().findFiles(p.DiskPath)
 	if err != nil {
 		return err
 	}
@@ -200,16 +267,16 @@ nextFile:
 		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
 		base := BasenameWithoutExtension(filename)
 		if ext == "yml" || ext == "yaml" {
-			md, err := ProcessMetadata(filename)
+			md, err := p.Tacker.source().processMetadata(filename)
 			if err != nil {
-				return fmt.Errorf("unable to process metadata for %s: %w", p.Permalink(), err)
+				return fmt.Errorf("unable to process metadata for %s: %w", p.Permalink(), withPermalink(err, p.Permalink()))
 			}
 			md["template"] = base
-			if err := p.addVariables(md); err != nil {
+			if err := p.addVariables(filename, md); err != nil {
 				return err
 			}
 		} else if ext == "md" || ext == "mkd" {
-			markdown, err := os.ReadFile(filename)
+			markdown, err := p.Tacker.source().ReadFile(filename)
 			if err != nil {
 				return err
 			}
@@ -219,11 +286,12 @@ nextFile:
 			if err := engine.Convert(markdown, buf, parser.WithContext(context)); err != nil {
 				return err
 			}
-			if err := p.addVariables(meta.Get(context)); err != nil {
+			if err := p.addVariables(filename, meta.Get(context)); err != nil {
 				return err
 			}
 
 			p.Variables[base] = buf.String()
+			p.Sources[base] = filename
 		} else {
 			p.Assets[strings.TrimPrefix(filename, p.DiskPath)] = struct{}{}
 		}
@@ -233,7 +301,122 @@ nextFile:
 	return nil
 }
 
-func (p *Page) addVariables(md map[string]interface{}) error {
+// link resolves the page's parent, siblings, children, and posts from the
+// Tacker's pages.
+func (p *Page) link() {
+	parent := filepath.Dir(p.DiskPath)
+	p.Parent = nil
+	siblingsAndMe := []*Page{}
+	children := []*Page{}
+	posts := []*Page{}
+
+	for _, i := range p.Tacker.Pages {
+		if i.DiskPath == parent {
+			p.Parent = i
+		}
+		if filepath.Dir(i.DiskPath) == parent && !i.Floating && !i.Post() {
+			siblingsAndMe = append(siblingsAndMe, i)
+		}
+		if filepath.Dir(i.DiskPath) == p.DiskPath {
+			if !i.Post() && !i.Floating {
+				children = append(children, i)
+			} else if i.Post() {
+				posts = append(posts, i)
+			}
+		}
+	}
+
+	sort.Slice(siblingsAndMe, func(i, j int) bool {
+		return strings.Compare(filepath.Base(siblingsAndMe[i].DiskPath), filepath.Base(siblingsAndMe[j].DiskPath)) == -1
+	})
+	p.SiblingsAndMe = siblingsAndMe
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Compare(filepath.Base(children[i].DiskPath), filepath.Base(children[j].DiskPath)) == -1
+	})
+	p.Children = children
+	sortPosts(posts)
+	p.Posts = posts
+}
+
+// sortPosts sorts posts by date, newest first. Posts of the same date are
+// ordered by their directory names, so that the order is stable.
+func sortPosts(posts []*Page) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].Date.Equal(posts[j].Date) {
+			return filepath.Base(posts[i].DiskPath) < filepath.Base(posts[j].DiskPath)
+		}
+		return posts[i].Date.After(posts[j].Date)
+	})
+}
+
+// chronology orders posts by date to look up the posts published right
+// before and after a post.
+type chronology struct {
+	posts []*Page
+	index map[*Page]int
+}
+
+func newChronology(pages []*Page) *chronology {
+	c := &chronology{index: map[*Page]int{}}
+	for _, i := range pages {
+		if i.Post() {
+			c.posts = append(c.posts, i)
+		}
+	}
+	sortPosts(c.posts)
+	for idx, i := range c.posts {
+		c.index[i] = idx
+	}
+
+	return c
+}
+
+// adjacent returns the posts published right before (previous) and after
+// (next) the given one.
+func (c *chronology) adjacent(p *Page) (previous, next *Page) {
+	if c == nil {
+		return nil, nil
+	}
+	idx, ok := c.index[p]
+	if !ok {
+		return nil, nil
+	}
+	if idx+1 < len(c.posts) {
+		previous = c.posts[idx+1]
+	}
+	if idx > 0 {
+		next = c.posts[idx-1]
+	}
+
+	return previous, next
+}
+
+// Draft returns true if the page, or any of its ancestors, is marked as a
+// draft using `draft: true`.
+func (p *Page) Draft() bool {
+	for i := p; i != nil; i = i.Parent {
+		if v, ok := i.Variables["draft"].(bool); ok && v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scheduled returns true if the page is a post, or below a post, dated after
+// the Tacker's PublishTime().
+func (p *Page) Scheduled() bool {
+	publishTime := p.Tacker.PublishTime()
+	for i := p; i != nil; i = i.Parent {
+		if i.Post() && i.Date.After(publishTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Page) addVariables(filename string, md map[string]interface{}) error {
 	for k, v := range md {
 		if k == "template" {
 			if p.Template != "" {
@@ -242,6 +425,15 @@ func (p *Page) addVariables(md map[string]interface{}) error {
 			p.Template = fmt.Sprint(v)
 			continue
 		}
+		if k == "date" && p.Post() {
+			// anything but a timestamp, ie. "Spring 2012", is kept as a
+			// regular page variable
+			if d, err := parseTime(v, p.Tacker.Location()); err == nil {
+				p.Date = d
+				delete(p.Variables, k)
+				continue
+			}
+		}
 		if k == "tags" {
 			if bv, ok := v.(bool); ok && bv {
 				p.addTagPages = true
@@ -255,10 +447,12 @@ func (p *Page) addVariables(md map[string]interface{}) error {
 						continue
 					}
 					p.Tacker.addTag(s, p)
+					p.tagNames = append(p.tagNames, s)
 				}
 			}
 		}
 		p.Variables[k] = v
+		p.Sources[k] = filename
 	}
 
 	return nil
@@ -268,6 +462,44 @@ func (p *Page) addVariables(md map[string]interface{}) error {
 // from disk and the configured template. If not done already, calling this
 // function will initialize the page using Init().
 func (p *Page) Generate() error {
+	defer useMissingVariables(!p.Tacker.Strict)()
+	return p.generate(p.Tacker.newBuild(nil, &DirSink{Dir: p.Tacker.Dir(TargetDir)}))
+}
+
+// Render fills the page's template and writes the result to w without
+// creating any files. If not done already, calling this function will
+// initialize the page using Init().
+func (p *Page) Render(w io.Writer) error {
+	if !p.inited {
+		if err := p.Init(); err != nil {
+			return err
+		}
+	}
+
+	tpl, err := p.Tacker.FindTemplate(p.Template)
+	if err != nil {
+		return fmt.Errorf("unable to load template '%s' when rendering '%s': %w", p.Template, p.Permalink(), withPermalink(err, p.Permalink()))
+	}
+
+	defer useMissingVariables(!p.Tacker.Strict)()
+	return p.render(tpl, RenderContext(p), w)
+}
+
+func (p *Page) render(tpl *Template, ctx map[string]interface{}, w io.Writer) error {
+	if err := tpl.FRender(w, ctx); err != nil {
+		return &RenderError{Permalink: p.Permalink(), Template: p.Template, Err: err}
+	}
+
+	return nil
+}
+
+// generate renders the page as part of the given build. All debugging output
+// is buffered and written at once, so that multiple pages can be generated
+// concurrently.
+func (p *Page) generate(b *build) error {
+	log := &debugBuffer{tacker: p.Tacker}
+	defer log.Flush()
+
 	if !p.inited {
 		if err := p.Init(); err != nil {
 			return err
@@ -284,48 +516,99 @@ func (p *Page) Generate() error {
 		s = append(s, i.Slug)
 	}
 
-	destDir := filepath.Join(append([]string{p.Tacker.BaseDir, TargetDir}, p.TargetDir()...)...)
+	relDir := filepath.Join(p.TargetDir()...)
+	destDir := filepath.Join(b.targetDir, relDir)
 
-	p.Tacker.Debug("Generating %s", p.Slug)
+	log.Debug("%s => %s (template: %s)", p.Permalink(), p.Slug, p.Template)
+	log.Debug("Generating %s", p.Slug)
 	par := "-"
 	if p.Parent != nil {
 		par = p.Parent.DiskPath
 	}
-	p.Tacker.Debug(" - disk path: %s", p.DiskPath)
-	p.Tacker.Debug(" - parent: %s", par)
-	p.Tacker.Debug(" - permalink: %s", p.Permalink())
-	p.Tacker.Debug(" - destdir: %s", destDir)
-	p.Tacker.Debug(" - ancestors: %s", strings.Join(a, " << "))
-	p.Tacker.Debug(" - siblings: %s", strings.Join(s, ", "))
-
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return err
-	}
+	log.Debug(" - disk path: %s", p.DiskPath)
+	log.Debug(" - parent: %s", par)
+	log.Debug(" - permalink: %s", p.Permalink())
+	log.Debug(" - destdir: %s", destDir)
+	log.Debug(" - ancestors: %s", strings.Join(a, " << "))
+	log.Debug(" - siblings: %s", strings.Join(s, ", "))
 
 	tpl, err := p.Tacker.FindTemplate(p.Template)
 	if err != nil {
-		return fmt.Errorf("unable to load template '%s' when rendering '%s': %s", p.Template, p.Permalink(), err)
+		return fmt.Errorf("unable to load template '%s' when rendering '%s': %w", p.Template, p.Permalink(), withPermalink(err, p.Permalink()))
 	}
 
-	f, err := os.OpenFile(filepath.Join(destDir, "index.html"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
+	ctx := RenderContext(p)
+	if b.upToDate(filepath.Join(relDir, "index.html"), fingerprint(tpl.fingerprint, ctx)) {
+		log.Debug(" - unchanged, skipping")
+		// still rendered in strict mode, so that errors keep being reported
+		if p.Tacker.Strict {
+			if err := p.render(tpl, ctx, io.Discard); err != nil {
+				return err
+			}
+		}
+	} else {
+		f, err := b.create(filepath.Join(relDir, "index.html"))
+		if err != nil {
+			return err
+		}
+
+		if err := p.render(tpl, ctx, f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
 	}
-	defer f.Close()
 
-	if err := tpl.Render(p, f); err != nil {
-		return fmt.Errorf("unable to render template '%s' when rendering '%s': %s", p.Template, p.Permalink(), err)
+	if err := p.generateFeeds(b, relDir); err != nil {
+		return err
 	}
 
 	for i := range p.Assets {
-		p.Tacker.Debug("Copying ...%s", i)
-		if err := os.MkdirAll(filepath.Dir(filepath.Join(destDir, i)), 0755); err != nil {
-			return err
-		}
-		if err := CopyFile(filepath.Join(p.DiskPath, i), filepath.Join(destDir, i)); err != nil {
+		log.Debug("Copying ...%s", i)
+		if err := b.copyFile(filepath.Join(p.DiskPath, i), filepath.Join(relDir, i)); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// RenderError is returned if filling a page's template fails, ie. because
+// an undefined variable is used in strict mode.
+type RenderError struct {
+	Permalink string
+	Template  string
+	Err       error
+}
+
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("unable to render template '%s' when rendering '%s': %s", e.Template, e.Permalink, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// debugBuffer collects the debugging output of a single page, so that it can
+// be written to the Tacker's DebugLogger all at once.
+type debugBuffer struct {
+	tacker *Tacker
+	lines  []string
+}
+
+func (d *debugBuffer) Debug(format string, args ...interface{}) {
+	if d.tacker.DebugLogger == nil {
+		return
+	}
+	d.lines = append(d.lines, fmt.Sprintf(format, args...))
+}
+
+func (d *debugBuffer) Flush() {
+	if len(d.lines) == 0 {
+		return
+	}
+	d.tacker.Debug("%s", strings.Join(d.lines, "\n"))
+	d.lines = nil
+}
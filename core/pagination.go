@@ -0,0 +1,203 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PaginationSlug is the name of the directory below a page, which contains
+// the further pages of its posts, ie. “/blog/page/2”.
+const PaginationSlug = "page"
+
+// pageSize returns the number of posts per page, as configured using the
+// `paginate` page setting, or 0 if the page's posts are not paginated.
+func (p *Page) pageSize() int {
+	v, ok := p.Variables["paginate"].(int)
+	if !ok || v < 1 {
+		return 0
+	}
+
+	return v
+}
+
+// listsPosts returns true if the rendering context of the page contains a
+// list of posts.
+func (p *Page) listsPosts() bool {
+	return !p.Post() && (!p.addTagPages || len(p.Posts) > 0)
+}
+
+// listedPosts returns the posts listed on the page: Its own posts, or—if it
+// does not have any—the ones of its parent, or the top-level posts in case of
+// a top-level page.
+func (p *Page) listedPosts() []*Page {
+	posts := p.Posts
+	if len(posts) == 0 && p.Parent != nil && len(p.Parent.Posts) > 0 {
+		posts = p.Parent.Posts
+	} else if len(posts) == 0 && p.Parent == nil {
+		for _, i := range p.Tacker.Posts {
+			if i.Parent == nil {
+				posts = append(posts, i)
+			}
+		}
+	}
+
+	return posts
+}
+
+// listedChronology returns the chronology of the posts listed on the page.
+func (p *Page) listedChronology() *chronology {
+	if len(p.Posts) > 0 {
+		return p.chronology
+	}
+	if p.Parent != nil {
+		// nil, if the parent does not have any posts either
+		return p.Parent.chronology
+	}
+
+	return p.Tacker.chronology
+}
+
+// pageCount returns the number of pages the page's posts are split into.
+func (p *Page) pageCount() int {
+	size := p.pageSize()
+	if size == 0 {
+		return 1
+	}
+
+	n := (len(p.listedPosts()) + size - 1) / size
+	if n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+// paginate adds a page for every further page of posts of all pages using
+// the `paginate` setting. These pages are copies of the original one, which
+// is the first page. As the assets are only copied to the first page, the
+// relative links in the content of the further pages are resolved against
+// the first one. Paginating a page which has a child page of the slug
+// PaginationSlug fails, as both would be written to the same directory.
+func (t *Tacker) paginate() error {
+	for _, i := range t.Pages {
+		if i.pageSize() == 0 || !i.listsPosts() {
+			continue
+		}
+		for _, j := range t.Pages {
+			if j.Parent == i && j.Slug == PaginationSlug {
+				return fmt.Errorf("unable to paginate %s: conflicts with child page %s", i.Permalink(), j.Permalink())
+			}
+		}
+
+		i.pageNumber = 1
+		variables := i.firstPageVariables()
+		for n := 2; n <= i.pageCount(); n++ {
+			page := *i
+			page.first = i
+			page.pageNumber = n
+			page.Assets = map[string]struct{}{}
+			page.Variables = variables
+			t.Pages = append(t.Pages, &page)
+		}
+	}
+
+	return nil
+}
+
+// firstPageVariables returns the page's variables with all relative links in
+// its Markdown content pointing to the page itself.
+func (p *Page) firstPageVariables() map[string]interface{} {
+	base, err := url.Parse(strings.TrimSuffix(p.Tacker.Link(p.Permalink()), "/") + "/")
+	if err != nil {
+		return p.Variables
+	}
+
+	r := map[string]interface{}{}
+	for k, v := range p.Variables {
+		ext := strings.ToLower(filepath.Ext(p.Sources[k]))
+		if s, ok := v.(string); ok && (ext == ".md" || ext == ".mkd") {
+			v = resolveLinks(s, base, false)
+		}
+		r[k] = v
+	}
+
+	return r
+}
+
+// FirstPage returns the page whose posts are continued on this page, ie.
+// “/blog” for “/blog/page/2”, or nil if this is not a further page of posts.
+func (p *Page) FirstPage() *Page {
+	return p.first
+}
+
+// pagePermalink returns the permalink of the given page of posts.
+func (p *Page) pagePermalink(n int) string {
+	first := p
+	if p.first != nil {
+		first = p.first
+	}
+	if n <= 1 {
+		return first.Permalink()
+	}
+
+	return path.Join(first.Permalink(), PaginationSlug, strconv.Itoa(n))
+}
+
+// pageOfPosts returns the posts shown on the page, if its posts are
+// paginated.
+func (p *Page) pageOfPosts(posts []*Page) []*Page {
+	size := p.pageSize()
+	start := 0
+	if p.pageNumber > 1 {
+		start = (p.pageNumber - 1) * size
+	}
+	if start > len(posts) {
+		return []*Page{}
+	}
+	end := start + size
+	if end > len(posts) {
+		end = len(posts)
+	}
+
+	return posts[start:end]
+}
+
+// PaginationValues returns the `pagination` variable of pages whose posts are
+// paginated: The number of the current page, the total number of pages, the
+// permalinks of the previous and next pages, as well as a list of all pages.
+func PaginationValues(p *Page) map[string]interface{} {
+	total := p.pageCount()
+	current := p.pageNumber
+	if current < 1 {
+		current = 1
+	}
+
+	pages := []map[string]interface{}{}
+	for n := 1; n <= total; n++ {
+		pages = append(pages, map[string]interface{}{
+			"number":    n,
+			"permalink": p.Tacker.Link(p.pagePermalink(n)),
+			"current":   n == current,
+		})
+	}
+
+	data := map[string]interface{}{
+		"current":            current,
+		"total":              total,
+		"previous_permalink": "",
+		"next_permalink":     "",
+		"pages":              pages,
+	}
+	if current > 1 {
+		data["previous_permalink"] = p.Tacker.Link(p.pagePermalink(current - 1))
+	}
+	if current < total {
+		data["next_permalink"] = p.Tacker.Link(p.pagePermalink(current + 1))
+	}
+
+	return data
+}
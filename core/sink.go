@@ -0,0 +1,234 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives the files of a tacked site. Names are slash-separated paths
+// relative to the root of the site, ie. “blog/first-post/index.html”.
+// Implementations need to be safe for concurrent use, as pages are generated
+// in parallel.
+type Sink interface {
+	// Create returns a writer for the file of the given name, replacing any
+	// previous version of the file once the writer is closed.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// DirSink writes all files into a directory on disk.
+type DirSink struct {
+	Dir string
+}
+
+// Create creates the file below the sink's directory, including all necessary
+// parent directories. A previous version of the file is removed first, as it
+// might be hard-linked to the output of an earlier build.
+func (s *DirSink) Create(name string) (io.WriteCloser, error) {
+	fn := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// MemorySink keeps all files in memory. It implements fs.FS, so the site can
+// be served using http.FS() without ever writing it to disk.
+type MemorySink struct {
+	mutex sync.RWMutex
+	files map[string]*memoryFile
+}
+
+type memoryFile struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemorySink creates an empty in-memory sink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: map[string]*memoryFile{}}
+}
+
+// Create returns a writer which stores the file once it is closed.
+func (s *MemorySink) Create(name string) (io.WriteCloser, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return &memoryWriter{sink: s, name: name}, nil
+}
+
+// Names returns the names of all files in the sink, sorted alphabetically.
+func (s *MemorySink) Names() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	r := []string{}
+	for name := range s.files {
+		r = append(r, name)
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// ReadFile returns the content of the file of the given name.
+func (s *MemorySink) ReadFile(name string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	f, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte{}, f.data...), nil
+}
+
+// Open implements fs.FS.
+func (s *MemorySink) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if f, ok := s.files[name]; ok {
+		return &openMemoryFile{memoryFile: f, Reader: bytes.NewReader(f.data)}, nil
+	}
+
+	// all parent directories of stored files exist implicitly
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	entries := map[string]fs.DirEntry{}
+	for fn, f := range s.files {
+		if !strings.HasPrefix(fn, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(fn, prefix)
+		if idx := strings.Index(rest, "/"); idx >= 0 {
+			entries[rest[:idx]] = memoryDirEntry{name: rest[:idx], dir: true}
+		} else {
+			entries[rest] = memoryDirEntry{name: rest, file: f}
+		}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, i := range entries {
+		list = append(list, i)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+
+	return &memoryDir{name: path.Base(name), entries: list}, nil
+}
+
+type memoryWriter struct {
+	bytes.Buffer
+	sink *MemorySink
+	name string
+}
+
+func (w *memoryWriter) Close() error {
+	w.sink.mutex.Lock()
+	defer w.sink.mutex.Unlock()
+
+	w.sink.files[w.name] = &memoryFile{name: path.Base(w.name), data: w.Bytes(), modTime: time.Now()}
+	return nil
+}
+
+func (f *memoryFile) Name() string       { return f.name }
+func (f *memoryFile) Size() int64        { return int64(len(f.data)) }
+func (f *memoryFile) Mode() fs.FileMode  { return 0444 }
+func (f *memoryFile) ModTime() time.Time { return f.modTime }
+func (f *memoryFile) IsDir() bool        { return false }
+func (f *memoryFile) Sys() interface{}   { return nil }
+
+type openMemoryFile struct {
+	*memoryFile
+	*bytes.Reader
+}
+
+func (f *openMemoryFile) Stat() (fs.FileInfo, error) { return f.memoryFile, nil }
+func (f *openMemoryFile) Close() error               { return nil }
+
+type memoryDir struct {
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memoryDir) Name() string               { return d.name }
+func (d *memoryDir) Size() int64                { return 0 }
+func (d *memoryDir) Mode() fs.FileMode          { return fs.ModeDir | 0555 }
+func (d *memoryDir) ModTime() time.Time         { return time.Time{} }
+func (d *memoryDir) IsDir() bool                { return true }
+func (d *memoryDir) Sys() interface{}           { return nil }
+func (d *memoryDir) Stat() (fs.FileInfo, error) { return d, nil }
+func (d *memoryDir) Close() error               { return nil }
+
+func (d *memoryDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *memoryDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+
+	return rest[:n], nil
+}
+
+type memoryDirEntry struct {
+	name string
+	dir  bool
+	file *memoryFile
+}
+
+func (e memoryDirEntry) Name() string { return e.name }
+func (e memoryDirEntry) IsDir() bool  { return e.dir }
+
+func (e memoryDirEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e memoryDirEntry) Info() (fs.FileInfo, error) {
+	if e.dir {
+		return &memoryDir{name: e.name}, nil
+	}
+	return e.file, nil
+}
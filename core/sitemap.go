@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// SitemapFile is the name of the sitemap written to the output directory if
+// the `sitemap` site metadata variable is set to `true`.
+const SitemapFile = "sitemap.xml"
+
+// ChangeFrequencies are the valid values of the `changefreq` page setting.
+var ChangeFrequencies = []string{"always", "hourly", "daily", "weekly", "monthly", "yearly", "never"}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// SitemapPages returns all pages to be listed in the sitemap, ordered by
+// permalink. Pages can be excluded using the `sitemap: false` page setting,
+// generated tag pages using the `sitemap_tags: false` site metadata variable.
+func (t *Tacker) SitemapPages() []*Page {
+	includeTags := t.Metadata["sitemap_tags"] != false
+
+	list := []*Page{}
+	for _, i := range t.Pages {
+		if i.Variables["sitemap"] == false {
+			continue
+		}
+		if !includeTags && i.Kind() == "tag" {
+			continue
+		}
+		list = append(list, i)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Permalink() < list[j].Permalink()
+	})
+
+	return list
+}
+
+// generateSitemap writes the sitemap as part of the given build, if enabled.
+func (t *Tacker) generateSitemap(b *build) error {
+	if t.Metadata["sitemap"] != true {
+		return nil
+	}
+
+	if u, err := t.BaseURL(); err != nil || u == nil {
+		return fmt.Errorf("unable to generate sitemap: base_url needs to be set in the site metadata")
+	}
+
+	set := &sitemapURLSet{}
+	for _, i := range t.SitemapPages() {
+		url, err := i.sitemapURL()
+		if err != nil {
+			return err
+		}
+		set.URLs = append(set.URLs, url)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+
+	if b.upToDate(SitemapFile, fingerprint(buf.String())) {
+		return nil
+	}
+	t.Debug("Writing %s", SitemapFile)
+	return b.writeFile(SitemapFile, buf.Bytes())
+}
+
+func (p *Page) sitemapURL() (sitemapURL, error) {
+	url := sitemapURL{Loc: p.Tacker.URL(p.Permalink())}
+	if p.Post() {
+		url.LastMod = p.Date.Format("2006-01-02")
+	}
+
+	if v, ok := p.Variables["changefreq"]; ok {
+		url.ChangeFreq = fmt.Sprint(v)
+		valid := false
+		for _, i := range ChangeFrequencies {
+			valid = valid || i == url.ChangeFreq
+		}
+		if !valid {
+			return url, fmt.Errorf("invalid changefreq for '%s': %s", p.Permalink(), url.ChangeFreq)
+		}
+	}
+
+	if v, ok := p.Variables["priority"]; ok {
+		f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil || f < 0 || f > 1 {
+			return url, fmt.Errorf("invalid priority for '%s': %v (needs to be between 0.0 and 1.0)", p.Permalink(), v)
+		}
+		url.Priority = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// source gives access to the source files of a site, which are read either
+// from disk or, if fsys is set, from an fs.FS. In both cases, file names are
+// OS-style paths. For an fs.FS, these are relative to its root.
+type source struct {
+	fsys fs.FS
+}
+
+// name converts an OS-style path into a name valid for the fs.FS.
+func (s source) name(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+func (s source) Open(path string) (fs.File, error) {
+	if s.fsys == nil {
+		return os.Open(path)
+	}
+
+	return s.fsys.Open(s.name(path))
+}
+
+func (s source) ReadFile(path string) ([]byte, error) {
+	if s.fsys == nil {
+		return os.ReadFile(path)
+	}
+
+	return fs.ReadFile(s.fsys, s.name(path))
+}
+
+func (s source) Stat(path string) (fs.FileInfo, error) {
+	if s.fsys == nil {
+		return os.Stat(path)
+	}
+
+	return fs.Stat(s.fsys, s.name(path))
+}
+
+func (s source) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if s.fsys == nil {
+		return filepath.WalkDir(root, fn)
+	}
+
+	return fs.WalkDir(s.fsys, s.name(root), func(path string, entry fs.DirEntry, err error) error {
+		return fn(filepath.FromSlash(path), entry, err)
+	})
+}
+
+func (s source) Glob(pattern string) ([]string, error) {
+	if s.fsys == nil {
+		return filepath.Glob(pattern)
+	}
+
+	m, err := fs.Glob(s.fsys, s.name(pattern))
+	for idx := range m {
+		m[idx] = filepath.FromSlash(m[idx])
+	}
+
+	return m, err
+}
+
+func (s source) dirExists(path string) bool {
+	st, err := s.Stat(path)
+	return err == nil && st.IsDir()
+}
+
+// source returns the source of the site's files.
+func (t *Tacker) source() source {
+	return source{fsys: t.FS}
+}
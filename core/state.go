@@ -0,0 +1,211 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateFile is the name of the file below the site directory which is used to
+// persist information about the generated output between incremental builds.
+const StateFile = ".tack-state"
+
+const stateVersion = 1
+
+// buildState maps each file of the output directory (relative to it) to a
+// fingerprint of all the inputs that were used to generate it.
+type buildState struct {
+	Version int               `json:"version"`
+	Target  string            `json:"target"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// build holds everything needed during a single run of Tack(). If a previous
+// state is available, outputs with unchanged fingerprints will not be written
+// again. It is safe to be used by multiple goroutines.
+type build struct {
+	mutex  sync.Mutex
+	tacker *Tacker
+	sink   Sink
+	// targetDir is the directory written to, if the sink is a DirSink. Only
+	// then, outputs of a previous build can be kept.
+	targetDir string
+	prev      *buildState
+	next      *buildState
+}
+
+func (t *Tacker) newBuild(prev *buildState, sink Sink) *build {
+	b := &build{
+		tacker: t,
+		sink:   sink,
+		next:   &buildState{Version: stateVersion, Target: t.Dir(TargetDir), Outputs: map[string]string{}},
+	}
+	if d, ok := sink.(*DirSink); ok {
+		b.targetDir = d.Dir
+		b.prev = prev
+	}
+
+	return b
+}
+
+// upToDate registers the output file (relative to the target directory) with
+// the given fingerprint and reports whether the file is still present from the
+// previous build with exactly the same fingerprint.
+func (b *build) upToDate(rel string, fingerprint string) bool {
+	b.mutex.Lock()
+	b.next.Outputs[filepath.ToSlash(rel)] = fingerprint
+	b.mutex.Unlock()
+
+	if b.prev == nil || b.targetDir == "" || b.prev.Outputs[filepath.ToSlash(rel)] != fingerprint {
+		return false
+	}
+
+	_, err := os.Stat(filepath.Join(b.targetDir, rel))
+	return err == nil
+}
+
+// create returns a writer for the output file (relative to the target
+// directory) using the build's sink.
+func (b *build) create(rel string) (io.WriteCloser, error) {
+	return b.sink.Create(filepath.ToSlash(rel))
+}
+
+// writeFile writes the output file (relative to the target directory) using
+// the build's sink.
+func (b *build) writeFile(rel string, data []byte) error {
+	w, err := b.create(rel)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// copyFile copies src to the path dest relative to the target directory,
+// unless it was already copied by the previous build and did not change since.
+func (b *build) copyFile(src string, dest string) error {
+	s, err := b.tacker.source().Stat(src)
+	if err != nil {
+		return err
+	}
+	if !s.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+	if b.upToDate(dest, fmt.Sprintf("%d:%d", s.Size(), s.ModTime().UnixNano())) {
+		return nil
+	}
+
+	source, err := b.tacker.source().Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	w, err := b.create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, source); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// removeStaleOutputs deletes all files which have been generated by the
+// previous build, but are not part of the current one anymore.
+func (b *build) removeStaleOutputs() error {
+	if b.prev == nil {
+		return nil
+	}
+
+	for rel := range b.prev.Outputs {
+		if _, ok := b.next.Outputs[rel]; ok {
+			continue
+		}
+		fn := filepath.Join(b.targetDir, filepath.FromSlash(rel))
+		b.tacker.Debug("Removing %s", rel)
+		if err := os.Remove(fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		// clean up directories which became empty
+		for dir := filepath.Dir(fn); dir != b.targetDir && len(dir) > len(b.targetDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadState reads the state of the last incremental build. As the state is
+// kept in the site directory, there is none for sites read from an fs.FS.
+func (t *Tacker) loadState() *buildState {
+	if t.FS != nil {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(t.BaseDir, StateFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	state := &buildState{}
+	if err := json.NewDecoder(f).Decode(state); err != nil {
+		t.Debug("Ignoring unreadable build state: %s", err)
+		return nil
+	}
+	if state.Version != stateVersion || state.Target != t.Dir(TargetDir) || state.Outputs == nil {
+		return nil
+	}
+
+	return state
+}
+
+func (t *Tacker) saveState(state *buildState) error {
+	if t.FS != nil {
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(t.BaseDir, StateFile), data, 0644)
+}
+
+func (t *Tacker) removeState() error {
+	if t.FS != nil {
+		return nil
+	}
+
+	if err := os.Remove(filepath.Join(t.BaseDir, StateFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// fingerprint creates a hash of all the values given.
+func fingerprint(values ...interface{}) string {
+	h := sha256.New()
+	for _, i := range values {
+		fmt.Fprintf(h, "%v\x00", i)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
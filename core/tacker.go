@@ -4,22 +4,33 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/cbroglie/mustache"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// Default locations of the site directories, relative to the base directory.
+// They can be changed using the respective fields of a Tacker, or the `tack`
+// section of the site metadata.
 const ContentDir = "content"
 const TemplateDir = "templates"
 const TargetDir = "output"
 const AssetDir = "public"
 
+// SettingsKey is the site metadata key of the section which holds tack's own
+// settings. This section is not available to templates.
+const SettingsKey = "tack"
+
 var TemplateExtensions = []string{"mustache", "mu", "stache"}
 var MetadataExtensions = []string{"yaml", "yml"}
 var MarkupExtensions = []string{"md", "mkd"}
@@ -28,34 +39,119 @@ var MarkupExtensions = []string{"md", "mkd"}
 // the command-line interface, or programmatically when tacking a website from
 // within third-party code.
 type Tacker struct {
-	BaseDir     string
-	Metadata    map[string]interface{}
-	Pages       []*Page
-	Navigation  []*Page
-	Posts       []*Page
-	Tags        map[string][]*Page
-	TagNames    map[string]map[string]int
-	TagIndex    *Page
-	Logger      *log.Logger
-	DebugLogger *log.Logger
-	Strict      bool
+	BaseDir string
+	// FS is the file system the site's sources are read from, if not read
+	// from BaseDir on disk. See NewTackerFS().
+	FS       fs.FS
+	Metadata map[string]interface{}
+	// MetadataSources maps the names of all site metadata variables to the
+	// files they were read from.
+	MetadataSources map[string]string
+	Pages           []*Page
+	Navigation      []*Page
+	Posts           []*Page
+	Tags            map[string][]*Page
+	TagNames        map[string]map[string]int
+	TagIndex        *Page
+	Logger          *log.Logger
+	DebugLogger     *log.Logger
+	Strict          bool
+	Incremental     bool
+	// Drafts includes pages marked using `draft: true` (and all pages below
+	// them) in the site. Changing it requires calling Reload().
+	Drafts bool
+	// Future includes posts dated after the PublishTime() (and all pages
+	// below them) in the site. Changing it requires calling Reload().
+	Future bool
+	// Jobs is the number of pages to generate concurrently. If not set,
+	// runtime.GOMAXPROCS will be used.
+	Jobs int
+
+	// ContentDir, TemplateDir, TargetDir, and AssetDir override the location
+	// of the respective site directory. Relative paths are resolved against
+	// BaseDir. Changing ContentDir or TemplateDir requires calling Reload().
+	ContentDir  string
+	TemplateDir string
+	TargetDir   string
+	AssetDir    string
+
+	// configured directories from the site metadata
+	siteDirs map[string]string
+	// configured time zone and publishing time from the site metadata
+	location    *time.Location
+	publishTime time.Time
+	baseURL     *url.URL
+	// time of the last call to Reload()
+	loaded time.Time
+
+	// unpublished are the pages excluded from the site, because they are
+	// drafts or scheduled posts.
+	unpublished []*Page
+	// chronology orders the top-level posts to look up their neighbours.
+	chronology *chronology
+
+	templates      map[string]*templateEntry
+	templatesMutex sync.Mutex
+}
+
+// templateEntry is a template in the cache. It might still be in the process
+// of being parsed, in which case ready is not closed yet.
+type templateEntry struct {
+	ready    chan struct{}
+	template *Template
+	err      error
+}
+
+// Options configure which pages are loaded by NewTackerWithOptions(). Setting
+// them when creating a Tacker saves calling Reload() after changing the
+// respective fields.
+type Options struct {
+	// Drafts sets Tacker.Drafts.
+	Drafts bool
+	// Future sets Tacker.Future.
+	Future bool
 }
 
 // NewTacker creates a new tack configuration structure based on the files
 // found in the directory provided.
 func NewTacker(dir string) (*Tacker, error) {
+	return NewTackerWithOptions(dir, Options{})
+}
+
+// NewTackerWithOptions works like NewTacker, but loads the site using the
+// given options.
+func NewTackerWithOptions(dir string, opts Options) (*Tacker, error) {
 	if !DirExists(dir) {
 		return nil, fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	if !DirExists(filepath.Join(dir, ContentDir)) || !DirExists(filepath.Join(dir, TemplateDir)) {
-		return nil, fmt.Errorf("does not look like a Tack-able site directory: %s", dir)
+	logger := log.New(os.Stdout, "", 0)
+
+	t := &Tacker{
+		BaseDir:     dir,
+		Logger:      logger,
+		DebugLogger: logger,
+		Drafts:      opts.Drafts,
+		Future:      opts.Future,
 	}
 
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// NewTackerFS creates a new tack configuration structure for the site found
+// at the root of the given file system, ie. an embed.FS. As the output cannot
+// be written to the file system, it needs to be tacked into a Sink using
+// TackTo(), or the TargetDir needs to be set to an absolute path.
+func NewTackerFS(fsys fs.FS) (*Tacker, error) {
 	logger := log.New(os.Stdout, "", 0)
 
 	t := &Tacker{
-		BaseDir:     dir,
+		BaseDir:     ".",
+		FS:          fsys,
 		Logger:      logger,
 		DebugLogger: logger,
 	}
@@ -69,23 +165,49 @@ func NewTacker(dir string) (*Tacker, error) {
 
 // Reload re-reads all site content and re-builds the page structure.
 func (t *Tacker) Reload() error {
+	t.templatesMutex.Lock()
+	t.templates = nil
+	t.templatesMutex.Unlock()
+
 	t.TagIndex = nil
 	t.Tags = nil
 	t.TagNames = nil
+	t.unpublished = nil
+	t.loaded = time.Now()
 
 	if err := t.loadSiteMetadata(); err != nil {
 		return err
 	}
+	if err := t.loadBaseURL(); err != nil {
+		return err
+	}
+	if err := t.checkTargetDir(); err != nil {
+		return err
+	}
+	if !t.source().dirExists(t.Dir(ContentDir)) || !t.source().dirExists(t.Dir(TemplateDir)) {
+		return fmt.Errorf("does not look like a Tack-able site directory: %s", t.BaseDir)
+	}
 	if err := t.findAllPages(); err != nil {
 		return err
 	}
 
-	navi := []*Page{}
-	posts := []*Page{}
 	for _, i := range t.Pages {
 		if err := i.Init(); err != nil {
 			return err
 		}
+		if _, err := i.locale(); err != nil {
+			return fmt.Errorf("unable to process %s: %w", i.Permalink(), err)
+		}
+	}
+	t.removeUnpublished()
+	// the dates of posts are only known after reading their files, too
+	for _, i := range t.Pages {
+		i.link()
+	}
+
+	navi := []*Page{}
+	posts := []*Page{}
+	for _, i := range t.Pages {
 		if i.Parent == nil && !i.Floating && !i.Post() {
 			navi = append(navi, i)
 		}
@@ -98,9 +220,7 @@ func (t *Tacker) Reload() error {
 	})
 	t.Navigation = navi
 
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date.After(posts[j].Date)
-	})
+	sortPosts(posts)
 	t.Posts = posts
 
 	for _, i := range t.Pages {
@@ -111,7 +231,14 @@ func (t *Tacker) Reload() error {
 			return fmt.Errorf("multiple tag index pages detected: %s <-> %s", t.TagIndex.DiskPath, i.DiskPath)
 		}
 		t.TagIndex = i
-		for slug, taggedPages := range t.Tags {
+		// sorted, so that the tag pages are always in the same order
+		slugs := []string{}
+		for slug := range t.Tags {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		for _, slug := range slugs {
+			taggedPages := t.Tags[slug]
 			tag := t.Tag(slug)
 
 			template := ""
@@ -120,6 +247,7 @@ func (t *Tacker) Reload() error {
 			}
 
 			vars := map[string]interface{}{}
+			sources := map[string]string{}
 			for k, v := range i.Variables {
 				if k == "name" {
 					continue
@@ -129,6 +257,7 @@ func (t *Tacker) Reload() error {
 					continue
 				}
 				vars[k] = v
+				sources[k] = i.Sources[k]
 			}
 			vars["count"] = tag.Count
 
@@ -143,15 +272,147 @@ func (t *Tacker) Reload() error {
 				Posts:     taggedPages,
 				Template:  template,
 				Variables: vars,
+				Sources:   sources,
 			}
 			t.Pages = append(t.Pages, page)
 			i.Children = append(i.Children, page)
 		}
 	}
 
+	topLevelPosts := []*Page{}
+	for _, i := range t.Posts {
+		if i.Parent == nil {
+			topLevelPosts = append(topLevelPosts, i)
+		}
+	}
+	t.chronology = newChronology(topLevelPosts)
+	for _, i := range t.Pages {
+		i.chronology = nil
+		if len(i.Posts) > 0 {
+			i.chronology = newChronology(i.Posts)
+		}
+	}
+
+	// added last, so that tag pages are paginated, too
+	return t.paginate()
+}
+
+// published returns true if the page is part of the site, taking into
+// account whether drafts and scheduled posts are to be included.
+func (t *Tacker) published(p *Page) bool {
+	return (t.Drafts || !p.Draft()) && (t.Future || !p.Scheduled())
+}
+
+// removeUnpublished removes all drafts and scheduled posts from the site's
+// pages, unless they are to be included. As a page's draft status is only
+// known after reading its files, the remaining pages are re-tagged
+// afterwards.
+func (t *Tacker) removeUnpublished() {
+	pages := []*Page{}
+	for _, i := range t.Pages {
+		if t.published(i) {
+			pages = append(pages, i)
+		} else {
+			t.unpublished = append(t.unpublished, i)
+		}
+	}
+	if len(t.unpublished) == 0 {
+		return
+	}
+
+	t.Pages = pages
+	t.Tags = nil
+	t.TagNames = nil
+	for _, i := range t.Pages {
+		for _, name := range i.tagNames {
+			t.addTag(name, i)
+		}
+	}
+}
+
+// FindPage returns the page with the given permalink, or nil if there is no
+// such page.
+func (t *Tacker) FindPage(permalink string) *Page {
+	permalink = path.Clean("/" + permalink)
+	for _, i := range t.Pages {
+		if i.Permalink() == permalink {
+			return i
+		}
+	}
+
 	return nil
 }
 
+// Dir returns the absolute path of one of the site directories ContentDir,
+// TemplateDir, TargetDir, or AssetDir, taking into account the locations
+// configured for the Tacker and in the site metadata.
+func (t *Tacker) Dir(name string) string {
+	dir := ""
+	switch name {
+	case ContentDir:
+		dir = t.ContentDir
+	case TemplateDir:
+		dir = t.TemplateDir
+	case TargetDir:
+		dir = t.TargetDir
+	case AssetDir:
+		dir = t.AssetDir
+	}
+	if dir == "" {
+		dir = t.siteDirs[name]
+	}
+	if dir == "" {
+		dir = name
+	}
+
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(t.BaseDir, dir)
+}
+
+// checkTargetDir makes sure tacking does not replace the site's own files, as
+// the output directory is removed before being written: It may neither be
+// the site directory or one of its parents, nor be, contain, or be located
+// inside of the content, template or asset directory.
+func (t *Tacker) checkTargetDir() error {
+	if t.FS != nil {
+		// the site is not read from the disk
+		return nil
+	}
+
+	target, err := filepath.Abs(t.Dir(TargetDir))
+	if err != nil {
+		return err
+	}
+	base, err := filepath.Abs(t.BaseDir)
+	if err != nil {
+		return err
+	}
+	if isWithin(base, target) {
+		return fmt.Errorf("unable to use %s as output directory, as it contains the site directory %s", target, base)
+	}
+
+	for _, name := range []string{ContentDir, TemplateDir, AssetDir} {
+		dir, err := filepath.Abs(t.Dir(name))
+		if err != nil {
+			return err
+		}
+		if isWithin(target, dir) || isWithin(dir, target) {
+			return fmt.Errorf("unable to use %s as output directory, as it overlaps with the %s directory %s", target, name, dir)
+		}
+	}
+
+	return nil
+}
+
+// stringSetting returns the site metadata variable of the given name, if it
+// is a string.
+func (t *Tacker) stringSetting(name string) string {
+	s, _ := t.Metadata[name].(string)
+	return s
+}
+
 func (t *Tacker) Log(format string, args ...interface{}) {
 	if t.Logger == nil {
 		return
@@ -168,33 +429,95 @@ func (t *Tacker) Debug(format string, args ...interface{}) {
 
 // Tack is the main “tacking” functionality: All pages are rendered into the
 // output directory by filling the respective templates with the page content.
+// In incremental mode, only outputs whose inputs changed since the last
+// incremental build are re-generated, and only outputs that are not part of
+// the site anymore are removed. Otherwise, the output directory is re-created
+// from scratch.
+//
+// The site is rendered into a staging directory next to the output directory
+// first, which replaces the output directory only if tacking was successful.
+// So, if tacking fails, the output of the last successful run stays intact.
 func (t *Tacker) Tack() error {
-	mustache.AllowMissingVariables = !t.Strict
+	targetDir := t.Dir(TargetDir)
+	if t.FS != nil && !filepath.IsAbs(targetDir) {
+		return fmt.Errorf("unable to tack into %s: the output directory needs to be an absolute path when reading the site from an fs.FS", targetDir)
+	}
+	if err := t.checkTargetDir(); err != nil {
+		return err
+	}
+
 	strictModeOn := ""
 	if t.Strict {
 		strictModeOn = " in strict mode"
 	}
 
-	t.Log("Tacking up %s (%d pages)%s", t.BaseDir, len(t.Pages), strictModeOn)
+	var prev *buildState
+	if t.Incremental {
+		prev = t.loadState()
+	} else if err := t.removeState(); err != nil {
+		return err
+	}
 
-	if _, err := os.Stat(filepath.Join(t.BaseDir, TargetDir)); err != nil && !errors.Is(err, os.ErrNotExist) {
+	stagingDir := StagingDir(targetDir)
+	if err := os.RemoveAll(stagingDir); err != nil {
 		return err
-	} else if err == nil {
-		if err := os.RemoveAll(filepath.Join(t.BaseDir, TargetDir)); err != nil {
+	}
+	defer os.RemoveAll(stagingDir)
+
+	if prev != nil {
+		t.Log("Tacking up %s (%d pages, incrementally)%s", t.BaseDir, len(t.Pages), strictModeOn)
+		if err := LinkTree(targetDir, stagingDir); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return err
 		}
+	} else {
+		t.Log("Tacking up %s (%d pages)%s", t.BaseDir, len(t.Pages), strictModeOn)
+	}
+
+	b := t.newBuild(prev, &DirSink{Dir: stagingDir})
+	if err := t.tackInto(b); err != nil {
+		return err
 	}
 
-	for _, page := range t.Pages {
-		t.Debug("%s => %s (template: %s)", page.Permalink(), page.Slug, page.Template)
-		if err := page.Generate(); err != nil {
+	if err := ReplaceDir(stagingDir, targetDir); err != nil {
+		return err
+	}
+
+	if t.Incremental {
+		return t.saveState(b.next)
+	}
+
+	return nil
+}
+
+// TackTo renders all pages and copies all assets into the given sink, ie. to
+// keep the site in memory using a MemorySink. The output directory is not
+// touched and incremental mode does not apply.
+func (t *Tacker) TackTo(sink Sink) error {
+	t.Log("Tacking up %s (%d pages)", t.BaseDir, len(t.Pages))
+	return t.tackInto(t.newBuild(nil, sink))
+}
+
+func (t *Tacker) tackInto(b *build) error {
+	if b.targetDir != "" {
+		if err := os.MkdirAll(b.targetDir, 0755); err != nil {
 			return err
 		}
 	}
 
-	assetDir := filepath.Join(t.BaseDir, AssetDir)
-	targetDir := filepath.Join(t.BaseDir, TargetDir)
-	assets, err := FindFiles(assetDir)
+	if err := t.generatePages(b); err != nil {
+		return err
+	}
+
+	if err := t.validateUnpublished(); err != nil {
+		return err
+	}
+
+	if err := t.generateSitemap(b); err != nil {
+		return err
+	}
+
+	assetDir := t.Dir(AssetDir)
+	assets, err := t.source().findFiles(assetDir)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -203,48 +526,156 @@ func (t *Tacker) Tack() error {
 			continue
 		}
 
-		dest := targetDir + strings.TrimPrefix(i, assetDir)
-		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		t.Debug("Copying %s", strings.TrimPrefix(i, assetDir))
+		if err := b.copyFile(i, strings.TrimPrefix(i, assetDir)); err != nil {
 			return err
 		}
+	}
 
-		t.Debug("Copying %s", strings.TrimPrefix(i, assetDir))
-		if err := CopyFile(i, dest); err != nil {
-			return err
+	return b.removeStaleOutputs()
+}
+
+// validateUnpublished renders all excluded drafts and scheduled posts without
+// writing them anywhere, so that problems are caught in strict mode before
+// they are published.
+func (t *Tacker) validateUnpublished() error {
+	if !t.Strict {
+		return nil
+	}
+
+	for _, i := range t.unpublished {
+		err := i.Render(io.Discard)
+		if err == nil {
+			continue
+		}
+		if i.Draft() {
+			return fmt.Errorf("invalid draft: %w", err)
 		}
+		return fmt.Errorf("invalid scheduled post: %w", err)
 	}
 
 	return nil
 }
 
+// generatePages renders all pages using a bounded number of workers. If
+// rendering fails for any page, the error of the first failing page (in order
+// of t.Pages) is returned and the remaining errors are logged.
+func (t *Tacker) generatePages(b *build) error {
+	jobs := t.Jobs
+	if jobs < 1 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+
+	defer useMissingVariables(!t.Strict)()
+
+	errs := make([]error, len(t.Pages))
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				errs[idx] = t.Pages[idx].generate(b)
+			}
+		}()
+	}
+	for idx := range t.Pages {
+		queue <- idx
+	}
+	close(queue)
+	wg.Wait()
+
+	var first error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		} else {
+			t.Log("Error: %s", err)
+		}
+	}
+
+	return first
+}
+
+// FindTemplate returns the parsed template of the given name. Templates are
+// cached until the next call to Reload(), or until they are invalidated using
+// InvalidateTemplates().
 func (t *Tacker) FindTemplate(name string) (*Template, error) {
 	if name == "" {
 		name = "default"
 	}
 
-	fn := FirstFileWithExtension(filepath.Join(t.BaseDir, TemplateDir), name, TemplateExtensions...)
-	if fn == "" {
-		return nil, fmt.Errorf("Template '%s' not found", name)
+	t.templatesMutex.Lock()
+	if e, ok := t.templates[name]; ok {
+		t.templatesMutex.Unlock()
+		<-e.ready
+		return e.template, e.err
 	}
-
-	provider := &mustache.FileProvider{
-		Paths:      []string{filepath.Join(t.BaseDir, TemplateDir)},
-		Extensions: []string{},
+	// other callers asking for the same template wait for it to be parsed
+	// instead of parsing it themselves
+	e := &templateEntry{ready: make(chan struct{})}
+	if t.templates == nil {
+		t.templates = map[string]*templateEntry{}
 	}
-	for _, i := range TemplateExtensions {
-		if i == "" {
-			provider.Extensions = append(provider.Extensions, i)
-		} else {
-			provider.Extensions = append(provider.Extensions, "."+i)
+	t.templates[name] = e
+	t.templatesMutex.Unlock()
+
+	e.template, e.err = t.parseTemplate(name)
+	if e.err != nil {
+		// errors are not cached, so that the template is tried again
+		t.templatesMutex.Lock()
+		if t.templates[name] == e {
+			delete(t.templates, name)
 		}
+		t.templatesMutex.Unlock()
 	}
+	close(e.ready)
 
-	tpl, err := mustache.ParseFilePartials(fn, provider)
-	if err != nil {
-		return nil, err
+	return e.template, e.err
+}
+
+func (t *Tacker) parseTemplate(name string) (*Template, error) {
+	dir := t.Dir(TemplateDir)
+	fn := t.source().firstFileWithExtension(dir, name, TemplateExtensions...)
+	if fn == "" {
+		return nil, fmt.Errorf("Template '%s' not found", name)
 	}
 
-	return &Template{tpl}, nil
+	return parseTemplate(t.source(), fn, dir)
+}
+
+// InvalidateTemplates removes all templates from the cache which depend on
+// any of the given files, either directly or by using them as a partial.
+// Templates that reference partials which did not exist when being loaded are
+// always removed.
+func (t *Tacker) InvalidateTemplates(files ...string) {
+	t.templatesMutex.Lock()
+	defer t.templatesMutex.Unlock()
+
+	for name, e := range t.templates {
+		select {
+		case <-e.ready:
+		default:
+			// still being parsed, possibly from the previous file contents
+			delete(t.templates, name)
+			continue
+		}
+		if e.template.incomplete {
+			delete(t.templates, name)
+			continue
+		}
+		for _, fn := range files {
+			if e.template.DependsOn(fn) {
+				t.Debug("Template '%s' changed", name)
+				delete(t.templates, name)
+				break
+			}
+		}
+	}
 }
 
 func (t *Tacker) addTag(name string, page *Page) {
@@ -302,9 +733,9 @@ func (t *Tacker) Tag(name string) Tag {
 }
 
 func (t *Tacker) findAllPages() error {
-	pagesPath := filepath.Join(t.BaseDir, ContentDir)
+	pagesPath := t.Dir(ContentDir)
 
-	m, err := FindDirsWithFiles(pagesPath, append(MarkupExtensions, MetadataExtensions...)...)
+	m, err := t.source().findDirsWithFiles(pagesPath, append(MarkupExtensions, MetadataExtensions...)...)
 	if err != nil {
 		return err
 	}
@@ -351,7 +782,11 @@ func (t *Tacker) findAllPages() error {
 }
 
 func ProcessMetadata(file string) (map[string]interface{}, error) {
-	r, err := os.Open(file)
+	return source{}.processMetadata(file)
+}
+
+func (s source) processMetadata(file string) (map[string]interface{}, error) {
+	r, err := s.Open(file)
 	if err != nil {
 		return nil, err
 	}
@@ -359,7 +794,7 @@ func ProcessMetadata(file string) (map[string]interface{}, error) {
 
 	res := map[string]interface{}{}
 	if err := yaml.NewDecoder(r).Decode(&res); err != nil && !errors.Is(err, io.EOF) {
-		return nil, err
+		return nil, newSourceError(file, err)
 	}
 	d := []string{}
 	for k, v := range res {
@@ -376,7 +811,11 @@ func ProcessMetadata(file string) (map[string]interface{}, error) {
 }
 
 func (t *Tacker) loadSiteMetadata() error {
-	files, err := filepath.Glob(filepath.Join(t.BaseDir, "*.*"))
+	t.siteDirs = nil
+	t.location = nil
+	t.publishTime = time.Time{}
+
+	files, err := t.source().Glob(filepath.Join(t.BaseDir, "*.*"))
 	if err != nil {
 		return err
 	}
@@ -384,18 +823,99 @@ func (t *Tacker) loadSiteMetadata() error {
 		if ext := strings.ToLower(filepath.Ext(i)); ext != ".yaml" && ext != ".yml" {
 			continue
 		}
-		md, err := ProcessMetadata(i)
+		md, err := t.source().processMetadata(i)
 		if err != nil {
 			return err
 		}
+		if settings, ok := md[SettingsKey].(map[interface{}]interface{}); ok {
+			if err := t.processSettings(i, settings); err != nil {
+				return err
+			}
+			delete(md, SettingsKey)
+		} else if md[SettingsKey] != nil {
+			// sites might use it as a regular variable
+			t.Debug("Not reading settings from '%s' in %s, as it is not a map", SettingsKey, i)
+		}
 		if t.Metadata == nil {
-			t.Metadata = md
-		} else {
-			for k, v := range md {
-				t.Metadata[k] = v
+			t.Metadata = map[string]interface{}{}
+		}
+		if t.MetadataSources == nil {
+			t.MetadataSources = map[string]string{}
+		}
+		for k, v := range md {
+			t.Metadata[k] = v
+			t.MetadataSources[k] = i
+		}
+	}
+
+	return nil
+}
+
+// processSettings reads tack's own settings from the `tack` section of a site
+// metadata file.
+func (t *Tacker) processSettings(file string, settings map[interface{}]interface{}) error {
+	var publishTime interface{}
+	for k, v := range settings {
+		key := fmt.Sprint(k)
+		switch key {
+		case "timezone":
+			name, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("unable to process %s: '%s.%s' needs to be a time zone name", file, SettingsKey, key)
+			}
+			loc, err := time.LoadLocation(name)
+			if err != nil {
+				return fmt.Errorf("unable to process %s: '%s.%s': %s", file, SettingsKey, key, err)
+			}
+			t.location = loc
+		case "publish_time":
+			publishTime = v
+		case ContentDir, TemplateDir, TargetDir, AssetDir:
+			dir, ok := v.(string)
+			if !ok || dir == "" {
+				return fmt.Errorf("unable to process %s: '%s.%s' needs to be a directory", file, SettingsKey, key)
+			}
+			if t.siteDirs == nil {
+				t.siteDirs = map[string]string{}
 			}
+			t.siteDirs[key] = dir
+		default:
+			return fmt.Errorf("unable to process %s: unknown setting '%s.%s'", file, SettingsKey, key)
 		}
 	}
 
+	// parsed last, as it depends on the configured time zone
+	if publishTime != nil {
+		ts, err := parseTime(publishTime, t.Location())
+		if err != nil {
+			return fmt.Errorf("unable to process %s: '%s.publish_time': %s", file, SettingsKey, err)
+		}
+		t.publishTime = ts
+	}
+
 	return nil
 }
+
+// Location returns the time zone dates without an explicit offset are
+// interpreted in, as configured using the `tack.timezone` setting. Defaults to
+// UTC instead of the local time zone, so that the site does not depend on the
+// machine it is tacked on.
+func (t *Tacker) Location() *time.Location {
+	if t.location == nil {
+		return time.UTC
+	}
+
+	return t.location
+}
+
+// PublishTime returns the point in time up to which posts are published.
+// Posts dated later are left out unless Future is set. It is configured using
+// the `tack.publish_time` setting and defaults to the time the site was
+// (re-)loaded.
+func (t *Tacker) PublishTime() time.Time {
+	if !t.publishTime.IsZero() {
+		return t.publishTime
+	}
+
+	return t.loaded
+}
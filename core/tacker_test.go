@@ -1,12 +1,19 @@
 package core
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/cbroglie/mustache"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -255,3 +262,945 @@ func StringSliceEqual(a []string, b []string) bool {
 
 	return true
 }
+
+// writeSite creates a site in a temporary directory, which is removed once
+// the test is finished. The files are given as slash-separated paths relative
+// to the site directory, mapped to their content.
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	base := t.TempDir()
+	for name, content := range files {
+		fn := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return base
+}
+
+func TestIncrementalTack(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache": "{{name}}: {{{body}}}",
+		"content/a/body.md":          "A",
+		"content/b/body.md":          "B",
+		"content/b/image.png":        "PNG",
+		"public/style.css":           "CSS",
+	})
+
+	tack := func() {
+		tacker, err := NewTacker(base)
+		assert.NoError(t, err)
+		tacker.Incremental = true
+		assert.NoError(t, tacker.Tack())
+	}
+
+	// mark all outputs as being old, so we're able to detect re-writes
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	outputs := []string{"index.html", "a/index.html", "b/index.html", "b/image.png", "style.css"}
+	age := func() {
+		for _, fn := range outputs {
+			assert.NoError(t, os.Chtimes(filepath.Join(base, TargetDir, fn), past, past))
+		}
+	}
+	rewritten := func(fn string) bool {
+		s, err := os.Stat(filepath.Join(base, TargetDir, fn))
+		assert.NoError(t, err)
+		return s != nil && !s.ModTime().Equal(past)
+	}
+
+	tack()
+	assert.FileExists(t, filepath.Join(base, StateFile))
+	age()
+
+	tack()
+	for _, fn := range outputs {
+		assert.False(t, rewritten(fn), "%s should not be re-written", fn)
+	}
+
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "content/b/body.md"), []byte("Changed"), 0644))
+	tack()
+	assert.True(t, rewritten("b/index.html"))
+	assert.False(t, rewritten("a/index.html"))
+	assert.False(t, rewritten("b/image.png"))
+	content, err := os.ReadFile(filepath.Join(base, TargetDir, "b/index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "B: <p>Changed</p>\n", string(content))
+
+	age()
+	assert.NoError(t, os.RemoveAll(filepath.Join(base, "content/a")))
+	tack()
+	assert.NoDirExists(t, filepath.Join(base, TargetDir, "a"))
+	assert.False(t, rewritten("b/index.html"))
+	assert.False(t, rewritten("style.css"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "templates/default.mustache"), []byte("{{name}}{{undefined}}"), 0644))
+	tack()
+	assert.True(t, rewritten("b/index.html"))
+	assert.True(t, rewritten("index.html"))
+
+	// unchanged pages are still validated in strict mode
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+	tacker.Incremental = true
+	tacker.Strict = true
+	var renderErr *RenderError
+	err = tacker.Tack()
+	assert.True(t, errors.As(err, &renderErr), err)
+}
+
+func TestIncrementalTackWithTags(t *testing.T) {
+	files := map[string]string{
+		"templates/default.mustache": "{{name}}:{{#menu}} {{name}}{{/menu}}{{#children}} {{name}}{{/children}}",
+		"content/tags/body.md":       "---\ntags: true\n---\n",
+	}
+	for _, tag := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files["content/2021-01-01."+tag+"/body.md"] = "---\ntags: [" + tag + "]\n---\n"
+	}
+	base := writeSite(t, files)
+
+	tack := func() {
+		tacker, err := NewTacker(base)
+		assert.NoError(t, err)
+		tacker.Incremental = true
+		assert.NoError(t, tacker.Tack())
+	}
+
+	tack()
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	outputs, err := FindFiles(filepath.Join(base, TargetDir))
+	assert.NoError(t, err)
+	assert.Len(t, outputs, 18)
+	for _, fn := range outputs {
+		assert.NoError(t, os.Chtimes(fn, past, past))
+	}
+
+	// the tag pages are in the same order every time, so nothing changes
+	tack()
+	for _, fn := range outputs {
+		s, err := os.Stat(fn)
+		assert.NoError(t, err)
+		assert.True(t, s.ModTime().Equal(past), "%s should not be re-written", fn)
+	}
+}
+
+func TestParallelTackErrors(t *testing.T) {
+	base, err := os.MkdirTemp(os.TempDir(), "tacktest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(base, TemplateDir), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(base, TemplateDir, "default.mustache"), []byte("{{name}}"), 0644))
+	for _, i := range []string{"1.a", "2.b", "3.c", "4.d"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(base, ContentDir, i), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(base, ContentDir, i, "missing.yaml"), []byte{}, 0644))
+	}
+
+	for run := 0; run < 10; run++ {
+		tacker, err := NewTacker(base)
+		assert.NoError(t, err)
+		tacker.Logger = nil
+		tacker.DebugLogger = nil
+		tacker.Jobs = 4
+		err = tacker.Tack()
+		assert.EqualError(t, err, "unable to load template 'missing' when rendering '/a': Template 'missing' not found")
+	}
+}
+
+func TestTagPageErrors(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache":      "{{name}}",
+		"content/tags/body.md":            "---\ntags: true\ntemplate_tags: missing\n---\n",
+		"content/2021-01-01.post/body.md": "---\ntags: [zeta, alpha, mu]\n---\n",
+	})
+
+	// the first error is reported, with tag pages ordered by their slugs
+	for run := 0; run < 10; run++ {
+		tacker, err := NewTacker(base)
+		assert.NoError(t, err)
+		tacker.Logger = nil
+		tacker.DebugLogger = nil
+		tacker.Jobs = 4
+		err = tacker.Tack()
+		assert.EqualError(t, err, "unable to load template 'missing' when rendering '/tags/alpha': Template 'missing' not found")
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache": "{{name}}{{#body}}{{> footer}}{{/body}}",
+		"templates/footer.mu":        "{{>copyright}}",
+		"templates/copyright.stache": "(c)",
+		"templates/simple.mustache":  "{{name}}{{> missing}}",
+		"content/index.md":           "Hello",
+	})
+
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+
+	def, err := tacker.FindTemplate("")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(base, "templates/default.mustache"), def.Filename)
+	assert.Equal(t, []string{
+		filepath.Join(base, "templates/copyright.stache"),
+		filepath.Join(base, "templates/footer.mu"),
+	}, def.Partials)
+	assert.True(t, def.DependsOn(filepath.Join(base, "templates/copyright.stache")))
+
+	same, err := tacker.FindTemplate("default")
+	assert.NoError(t, err)
+	assert.Same(t, def, same)
+
+	simple, err := tacker.FindTemplate("simple")
+	assert.NoError(t, err)
+	assert.Empty(t, simple.Partials)
+
+	footer, err := tacker.FindTemplate("footer")
+	assert.NoError(t, err)
+
+	tacker.InvalidateTemplates(filepath.Join(base, "templates/copyright.stache"))
+	fresh, err := tacker.FindTemplate("default")
+	assert.NoError(t, err)
+	assert.NotSame(t, def, fresh)
+	// referenced a missing partial, so it is always invalidated
+	freshSimple, err := tacker.FindTemplate("simple")
+	assert.NoError(t, err)
+	assert.NotSame(t, simple, freshSimple)
+
+	tacker.InvalidateTemplates(filepath.Join(base, "templates/default.mustache"))
+	again, err := tacker.FindTemplate("default")
+	assert.NoError(t, err)
+	assert.NotSame(t, fresh, again)
+
+	footerAgain, err := tacker.FindTemplate("footer")
+	assert.NoError(t, err)
+	assert.NotSame(t, footer, footerAgain)
+
+	// concurrent callers share the template parsed by the first one
+	tacker.InvalidateTemplates(filepath.Join(base, "templates/default.mustache"))
+	results := make([]*Template, 8)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = tacker.FindTemplate("default")
+		}(i)
+	}
+	wg.Wait()
+	for _, i := range results {
+		assert.NotNil(t, i)
+		assert.Same(t, results[0], i)
+	}
+
+	_, err = tacker.FindTemplate("nonexistent")
+	assert.Error(t, err)
+	assert.NotContains(t, tacker.templates, "nonexistent")
+}
+
+func TestFailingTackKeepsOutput(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache": "{{name}}",
+		"content/a/body.md":          "A",
+	})
+
+	for _, incremental := range []bool{false, true} {
+		assert.NoError(t, os.WriteFile(filepath.Join(base, "templates/default.mustache"), []byte("{{name}}"), 0644))
+		tacker, err := NewTacker(base)
+		assert.NoError(t, err)
+		tacker.Incremental = incremental
+		assert.NoError(t, tacker.Tack())
+
+		assert.NoError(t, os.WriteFile(filepath.Join(base, "templates/default.mustache"), []byte("{{#name}}"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(base, "content/a/body.md"), []byte("B"), 0644))
+		tacker, err = NewTacker(base)
+		assert.NoError(t, err)
+		tacker.Incremental = incremental
+		assert.Error(t, tacker.Tack())
+
+		content, err := os.ReadFile(filepath.Join(base, TargetDir, "a/index.html"))
+		assert.NoError(t, err)
+		assert.Equal(t, "A", string(content))
+		assert.NoDirExists(t, StagingDir(filepath.Join(base, TargetDir)))
+		assert.NoError(t, os.WriteFile(filepath.Join(base, "content/a/body.md"), []byte("A"), 0644))
+	}
+}
+
+func TestConfiguredDirectories(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"theme/layouts/default.mustache": "{{name}} {{title}}",
+		"site/pages/a/body.md":           "A",
+		"site/static/style.css":          "CSS",
+		"site/site.yaml":                 "title: Test\ntack:\n  content: pages\n  templates: ../theme/layouts\n  public: static\n  output: build\n",
+	})
+	site := filepath.Join(base, "site")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(site, "pages"), tacker.Dir(ContentDir))
+	assert.Equal(t, filepath.Join(base, "theme", "layouts"), tacker.Dir(TemplateDir))
+	assert.NotContains(t, tacker.Metadata, SettingsKey)
+	assert.Len(t, tacker.Pages, 2)
+
+	assert.NoError(t, tacker.Tack())
+	content, err := os.ReadFile(filepath.Join(site, "build", "a", "index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "A Test", string(content))
+	assert.FileExists(t, filepath.Join(site, "build", "style.css"))
+
+	// fields take precedence over the site metadata
+	tacker.TargetDir = filepath.Join(base, "www")
+	assert.NoError(t, tacker.Tack())
+	assert.FileExists(t, filepath.Join(base, "www", "a", "index.html"))
+
+	// templates outside of the site directory are checked for changes, too
+	checkpoint, err := tacker.Checkpoint()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "theme/layouts/other.mustache"), []byte{}, 0644))
+	changes, _, err := tacker.HasChanges(checkpoint)
+	assert.NoError(t, err)
+	assert.True(t, changes)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(site, "site.yaml"), []byte("tack:\n  templates: nonexistant\n"), 0644))
+	_, err = NewTacker(site)
+	assert.EqualError(t, err, "does not look like a Tack-able site directory: "+site)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(site, "site.yaml"), []byte("tack:\n  unknown: true\n"), 0644))
+	_, err = NewTacker(site)
+	assert.Error(t, err)
+
+	for _, output := range []string{".", "..", "pages", "pages/sub", "static", "../theme", filepath.Join(base, "theme/layouts")} {
+		assert.NoError(t, os.WriteFile(filepath.Join(site, "site.yaml"), []byte("tack:\n  content: pages\n  templates: ../theme/layouts\n  public: static\n"), 0644))
+		tacker, err := NewTacker(site)
+		assert.NoError(t, err)
+		tacker.TargetDir = output
+		assert.Error(t, tacker.Tack(), output)
+
+		assert.NoError(t, os.WriteFile(filepath.Join(site, "site.yaml"), []byte("tack:\n  content: pages\n  templates: ../theme/layouts\n  public: static\n  output: "+output+"\n"), 0644))
+		_, err = NewTacker(site)
+		assert.Error(t, err, output)
+	}
+	assert.FileExists(t, filepath.Join(site, "pages/a/body.md"))
+	assert.FileExists(t, filepath.Join(base, "theme/layouts/default.mustache"))
+
+	// not a map, so it is a regular variable
+	assert.NoError(t, os.WriteFile(filepath.Join(site, "site.yaml"), []byte("tack: Tack and stack\n"), 0644))
+	assert.NoError(t, os.Rename(filepath.Join(site, "pages"), filepath.Join(site, "content")))
+	assert.NoError(t, os.Rename(filepath.Join(base, "theme/layouts"), filepath.Join(site, "templates")))
+	tacker, err = NewTacker(site)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tack and stack", tacker.Metadata[SettingsKey])
+}
+
+func TestPageKinds(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+
+	for site, expected := range map[string]map[string]string{
+		"minimal": {
+			"/":         "root",
+			"/products": "ordered",
+			"/help":     "ordered",
+			"/about":    "ordered",
+		},
+		"minimal-blog-with-tags": {
+			"/":                          "root",
+			"/archive":                   "floating",
+			"/archive/releases":          "tag",
+			"/archive/useless-tags":      "tag",
+			"/first-tack-release":        "post",
+			"/tack-supports-dotnet-core": "post",
+			"/tack-version-one":          "post",
+		},
+	} {
+		tacker, err := NewTacker(filepath.Join(filepath.Dir(filename), "tests", site))
+		assert.NoError(t, err)
+		kinds := map[string]string{}
+		for _, p := range tacker.Pages {
+			kinds[p.Permalink()] = p.Kind()
+		}
+		assert.Equal(t, expected, kinds, "site: %s", site)
+	}
+}
+
+func TestContextSources(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "test-page-variable-overrides-site-metadata")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	assert.Nil(t, tacker.FindPage("/nonexistant"))
+
+	page := tacker.FindPage("/")
+	assert.NotNil(t, page)
+	assert.Same(t, page, tacker.FindPage(""))
+
+	ctx := RenderContext(page)
+	assert.Equal(t, "World", ctx["who"])
+	assert.Equal(t, map[string]string{
+		"who": filepath.Join(site, "content", "default.yaml"),
+	}, ContextSources(page, ctx))
+
+	tacker.Metadata["from_site"] = true
+	tacker.MetadataSources["from_site"] = filepath.Join(site, "site.yaml")
+	tacker.Metadata["permalink"] = "overridden by tack"
+	tacker.MetadataSources["permalink"] = filepath.Join(site, "site.yaml")
+	sources := ContextSources(page, RenderContext(page))
+	assert.Equal(t, filepath.Join(site, "site.yaml"), sources["from_site"])
+	assert.NotContains(t, sources, "permalink")
+}
+
+func TestRenderPage(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "blog")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	tacker.TargetDir = filepath.Join(site, "nonexistant")
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, tacker.FindPage("/posts/first-post").Render(buf))
+	expected, err := os.ReadFile(filepath.Join(site, "output.expected", "posts", "first-post", "index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+	assert.NoDirExists(t, tacker.Dir(TargetDir))
+}
+
+func TestFeeds(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "test-feeds")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	assert.NoError(t, tacker.Tack())
+	AssertDirEquals(t, filepath.Join(site, "output.expected"), filepath.Join(site, "output"))
+
+	assert.Equal(t, "/blog/feed.xml", tacker.FindPage("/blog").FeedPermalink())
+	assert.Equal(t, "/tags/news/feed.xml", tacker.FindPage("/tags/news").FeedPermalink())
+	assert.Equal(t, "", tacker.FindPage("/blog/first-post").FeedPermalink())
+
+	blog := tacker.FindPage("/blog")
+	assert.Equal(t, "Jane Doe", blog.atomFeed(FeedFile, blog.Posts).Author.Name)
+	delete(tacker.Metadata, "author")
+	assert.Equal(t, "Feed Test", blog.atomFeed(FeedFile, blog.Posts).Author.Name)
+
+	delete(tacker.Metadata, "base_url")
+	assert.NoError(t, tacker.loadBaseURL())
+	tacker.TargetDir = filepath.Join(site, "nonexistant")
+	assert.Error(t, tacker.Tack())
+	assert.NoDirExists(t, tacker.Dir(TargetDir))
+}
+
+func TestSitemap(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "test-sitemap")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	assert.NoError(t, tacker.Tack())
+	AssertDirEquals(t, filepath.Join(site, "output.expected"), filepath.Join(site, "output"))
+
+	permalinks := func() []string {
+		r := []string{}
+		for _, i := range tacker.SitemapPages() {
+			r = append(r, i.Permalink())
+		}
+		return r
+	}
+	tacker.Metadata["sitemap_tags"] = false
+	assert.Equal(t, []string{"/", "/blog", "/blog/first-post", "/blog/second-post", "/tags"}, permalinks())
+
+	tacker.TargetDir = filepath.Join(site, "nonexistant")
+	tacker.FindPage("/blog").Variables["priority"] = 2
+	assert.Error(t, tacker.Tack())
+	tacker.FindPage("/blog").Variables["priority"] = 0.5
+	tacker.FindPage("/blog").Variables["changefreq"] = "sometimes"
+	assert.Error(t, tacker.Tack())
+	assert.NoDirExists(t, tacker.Dir(TargetDir))
+}
+
+func TestBaseURL(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "minimal-blog-with-tags")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	if tacker.Metadata == nil {
+		tacker.Metadata = map[string]interface{}{}
+	}
+	post := tacker.FindPage("/first-tack-release")
+	assert.NotNil(t, post)
+
+	ctx := RenderContext(post)
+	assert.Equal(t, "/first-tack-release", ctx["permalink"])
+	assert.NotContains(t, ctx, "url")
+	assert.Equal(t, "", ctx["base_path"])
+
+	tacker.Metadata["base_url"] = "https://example.org/~team/"
+	assert.NoError(t, tacker.loadBaseURL())
+	ctx = RenderContext(post)
+	assert.Equal(t, "/~team/first-tack-release", ctx["permalink"])
+	assert.Equal(t, "https://example.org/~team/first-tack-release", ctx["url"])
+	assert.Equal(t, "/~team", ctx["base_path"])
+	assert.Equal(t, "/~team/", ctx["parent"].(map[string]interface{})["permalink"])
+	tags := ctx["tags"].([]map[string]interface{})
+	assert.Equal(t, "/~team/archive/useless-tags", tags[0]["permalink"])
+	assert.Equal(t, "https://example.org/~team/archive/useless-tags", tags[0]["url"])
+
+	tacker.Metadata["absolute_permalinks"] = true
+	ctx = RenderContext(post)
+	assert.Equal(t, "https://example.org/~team/first-tack-release", ctx["permalink"])
+	assert.Equal(t, "https://example.org/~team/archive/useless-tags", ctx["tags"].([]map[string]interface{})[0]["permalink"])
+
+	for _, i := range []string{"example.org", "/~team/", "://"} {
+		tacker.Metadata["base_url"] = i
+		assert.Error(t, tacker.loadBaseURL(), i)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache": "<h1>{{name}}</h1>\n{{#body}}\n",
+		"content/1.a/default.yaml":   "name: A\nbroken: [\n",
+	})
+	tpl := filepath.Join(base, TemplateDir, "default.mustache")
+	md := filepath.Join(base, ContentDir, "1.a", "default.yaml")
+
+	var sourceErr *SourceError
+	_, err := NewTacker(base)
+	assert.True(t, errors.As(err, &sourceErr), err)
+	assert.Equal(t, md, sourceErr.Filename)
+	assert.Equal(t, "/a", sourceErr.Permalink)
+	assert.Equal(t, 2, sourceErr.Line)
+
+	assert.NoError(t, os.WriteFile(md, []byte("name: A\n"), 0644))
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+	tacker.Logger = nil
+	err = tacker.Tack()
+	assert.True(t, errors.As(err, &sourceErr), err)
+	assert.Equal(t, tpl, sourceErr.Filename)
+	assert.Equal(t, "/a", sourceErr.Permalink)
+	assert.Equal(t, 3, sourceErr.Line)
+}
+
+func TestTackToMemory(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "test-feeds")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	tacker.Logger = nil
+	tacker.TargetDir = filepath.Join(site, "nonexistant")
+
+	sink := NewMemorySink()
+	assert.NoError(t, tacker.TackTo(sink))
+	assert.NoDirExists(t, tacker.Dir(TargetDir))
+
+	expected, err := FindFiles(filepath.Join(site, "output.expected"))
+	assert.NoError(t, err)
+	names := []string{}
+	for _, i := range expected {
+		rel, err := filepath.Rel(filepath.Join(site, "output.expected"), i)
+		assert.NoError(t, err)
+		names = append(names, filepath.ToSlash(rel))
+
+		data, err := sink.ReadFile(filepath.ToSlash(rel))
+		assert.NoError(t, err)
+		content, err := os.ReadFile(i)
+		assert.NoError(t, err)
+		assert.Equal(t, string(content), string(data), rel)
+	}
+	sort.Strings(names)
+	assert.Equal(t, names, sink.Names())
+	assert.NoError(t, fstest.TestFS(sink, names...))
+}
+
+func TestTackerFS(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	for _, name := range []string{"test-feeds", "minimal-blog-with-tags-below-index", "test-different-file-extensions"} {
+		site := filepath.Join(filepath.Dir(filename), "tests", name)
+
+		tacker, err := NewTackerFS(os.DirFS(site))
+		assert.NoError(t, err)
+		tacker.Logger = nil
+
+		sink := NewMemorySink()
+		assert.NoError(t, tacker.TackTo(sink))
+
+		expected, err := FindFiles(filepath.Join(site, "output.expected"))
+		assert.NoError(t, err)
+		assert.Len(t, sink.Names(), len(expected), name)
+		for _, i := range expected {
+			rel, err := filepath.Rel(filepath.Join(site, "output.expected"), i)
+			assert.NoError(t, err)
+			data, err := sink.ReadFile(filepath.ToSlash(rel))
+			assert.NoError(t, err)
+			content, err := os.ReadFile(i)
+			assert.NoError(t, err)
+			assert.Equal(t, string(content), string(data), rel)
+		}
+
+		assert.Error(t, tacker.Tack())
+	}
+
+	tacker, err := NewTackerFS(fstest.MapFS{
+		"site.yaml":                  {Data: []byte("title: In Memory\n")},
+		"templates/default.mustache": {Data: []byte("{{title}}: {{name}} {{> footer}}")},
+		"templates/footer.mustache":  {Data: []byte("(c) {{#children}}{{name}}{{/children}}")},
+		"content/body.md":            {Data: []byte("# Hello\n")},
+		"content/1.about/body.md":    {Data: []byte("About\n")},
+		"public/style.css":           {Data: []byte("body {}\n")},
+	})
+	assert.NoError(t, err)
+	tacker.Logger = nil
+	tacker.TargetDir = filepath.Join(t.TempDir(), "output")
+	assert.NoError(t, tacker.Tack())
+	index, err := os.ReadFile(filepath.Join(tacker.TargetDir, "index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "In Memory: Index (c) About", string(index))
+	assert.FileExists(t, filepath.Join(tacker.TargetDir, "style.css"))
+	assert.NoFileExists(t, StateFile)
+
+	_, err = NewTackerFS(fstest.MapFS{"content/body.md": {Data: []byte("Hello\n")}})
+	assert.Error(t, err)
+}
+
+func TestDrafts(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "test-drafts")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	tacker.Logger = nil
+	assert.NoError(t, tacker.Tack())
+	AssertDirEquals(t, filepath.Join(site, "output.expected"), filepath.Join(site, "output"))
+
+	permalinks := func(pages []*Page) []string {
+		r := []string{}
+		for _, i := range pages {
+			r = append(r, i.Permalink())
+		}
+		return r
+	}
+	assert.Equal(t, []string{"/", "/about", "/published", "/tags", "/tags/news"}, permalinks(tacker.Pages))
+	assert.Equal(t, []string{"/published"}, permalinks(tacker.Posts))
+	assert.Equal(t, []string{"/about"}, permalinks(tacker.FindPage("/").Children))
+	assert.Equal(t, 1, tacker.Tag("news").Count)
+	assert.Nil(t, tacker.Tags["work-in-progress"])
+	assert.Nil(t, tacker.FindPage("/secret/nested"))
+
+	tacker.Strict = true
+	assert.NoError(t, tacker.TackTo(NewMemorySink()))
+	for _, i := range tacker.unpublished {
+		delete(i.Variables, "body")
+	}
+	err = tacker.TackTo(NewMemorySink())
+	var renderErr *RenderError
+	assert.True(t, errors.As(err, &renderErr), err)
+	tacker.Strict = false
+	assert.NoError(t, tacker.TackTo(NewMemorySink()))
+
+	tacker.Drafts = true
+	assert.NoError(t, tacker.Reload())
+	assert.Equal(t, []string{"/unfinished", "/published"}, permalinks(tacker.Posts))
+	withDrafts, err := NewTackerWithOptions(tacker.BaseDir, Options{Drafts: true})
+	assert.NoError(t, err)
+	assert.True(t, withDrafts.Drafts)
+	assert.Equal(t, permalinks(tacker.Pages), permalinks(withDrafts.Pages))
+	assert.Equal(t, []string{"/about", "/secret"}, permalinks(tacker.FindPage("/").Children))
+	assert.Equal(t, 2, tacker.Tag("news").Count)
+	assert.Equal(t, 1, tacker.Tag("work-in-progress").Count)
+	assert.True(t, tacker.FindPage("/secret/nested").Draft())
+	assert.Equal(t, true, PageValues(tacker.FindPage("/unfinished"), nil)["draft"])
+	assert.Equal(t, false, PageValues(tacker.FindPage("/published"), nil)["draft"])
+	assert.Empty(t, tacker.unpublished)
+}
+
+func TestScheduledPosts(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache":                     "{{name}}\n",
+		"content/2026-10-17.past/body.md":                "Hello\n",
+		"content/2026-10-18.today/body.md":               "Hello\n",
+		"content/2026-10-19.tomorrow/body.md":            "Hello\n",
+		"content/2026-10-19.tomorrow/attachment/body.md": "Hello\n",
+		"site.yaml": "tack:\n  publish_time: 2026-10-18T00:30:00+02:00\n",
+	})
+	settings := filepath.Join(base, "site.yaml")
+
+	permalinks := func(pages []*Page) []string {
+		r := []string{}
+		for _, i := range pages {
+			r = append(r, i.Permalink())
+		}
+		return r
+	}
+
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, tacker.Location())
+	assert.Equal(t, []string{"/past"}, permalinks(tacker.Posts))
+
+	assert.NoError(t, os.WriteFile(settings, []byte("tack:\n  publish_time: 2026-10-18T00:30:00+02:00\n  timezone: Europe/Berlin\n"), 0644))
+	assert.NoError(t, tacker.Reload())
+	assert.Equal(t, "Europe/Berlin", tacker.Location().String())
+	assert.Equal(t, []string{"/today", "/past"}, permalinks(tacker.Posts))
+	assert.Equal(t, []string{"/past", "/", "/today"}, permalinks(tacker.Pages))
+
+	tacker.Future = true
+	assert.NoError(t, tacker.Reload())
+	assert.Equal(t, []string{"/tomorrow", "/today", "/past"}, permalinks(tacker.Posts))
+	assert.True(t, tacker.FindPage("/tomorrow/attachment").Scheduled())
+	assert.Equal(t, true, PageValues(tacker.FindPage("/tomorrow"), nil)["scheduled"])
+	assert.Equal(t, false, PageValues(tacker.FindPage("/today"), nil)["scheduled"])
+
+	tacker.Future = false
+	assert.NoError(t, os.WriteFile(settings, []byte("tack:\n  timezone: Europe/Berlin\n"), 0644))
+	assert.NoError(t, tacker.Reload())
+	assert.WithinDuration(t, time.Now(), tacker.PublishTime(), time.Minute)
+
+	assert.NoError(t, os.WriteFile(settings, []byte("tack:\n  publish_time: tomorrow\n"), 0644))
+	assert.Error(t, tacker.Reload())
+	assert.NoError(t, os.WriteFile(settings, []byte("tack:\n  timezone: Mars/Olympus_Mons\n"), 0644))
+	assert.Error(t, tacker.Reload())
+}
+
+func TestPostTimestamps(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache":                 "{{name}}\n",
+		"site.yaml":                                  "tack:\n  timezone: Europe/Berlin\n",
+		"content/2021-10-17.b/body.md":               "Hello\n",
+		"content/2021-10-17.a/body.md":               "Hello\n",
+		"content/2021-10-18T09-15.morning/body.md":   "Hello\n",
+		"content/2021-10-18T14-30-15.lunch/body.md":  "Hello\n",
+		"content/2021-10-18.override/body.md":        "---\ndate: 2021-10-18T20:00:00Z\n---\nHello\n",
+		"content/2021-10-18T07-00-breakfast/body.md": "Hello\n",
+	})
+
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+
+	permalinks := []string{}
+	for _, i := range tacker.Posts {
+		permalinks = append(permalinks, i.Permalink())
+	}
+	assert.Equal(t, []string{"/override", "/lunch", "/morning", "/breakfast", "/a", "/b"}, permalinks)
+	assert.Equal(t, permalinks, func() []string {
+		r := []string{}
+		for _, i := range tacker.FindPage("/").Posts {
+			r = append(r, i.Permalink())
+		}
+		return r
+	}())
+
+	values := PageValues(tacker.FindPage("/lunch"), nil)
+	assert.Equal(t, "2021-10-18T14:30:15+02:00", values["timestamp"])
+	assert.Equal(t, "2021-10-18", values["date"])
+	assert.Equal(t, "2021-10-18T20:00:00Z", PageValues(tacker.FindPage("/override"), nil)["timestamp"])
+	assert.Equal(t, "2021-10-17T00:00:00+02:00", PageValues(tacker.FindPage("/a"), nil)["timestamp"])
+
+	// a date which is not a timestamp is kept as a regular variable
+	assert.NoError(t, os.WriteFile(filepath.Join(base, ContentDir, "2021-10-18.override", "body.md"), []byte("---\ndate: Spring 2012\n---\n"), 0644))
+	assert.NoError(t, tacker.Reload())
+	values = PageValues(tacker.FindPage("/override"), nil)
+	assert.Equal(t, "Spring 2012", values["date"])
+	assert.Equal(t, "2021-10-18T00:00:00+02:00", values["timestamp"])
+}
+
+func TestDateFormats(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache":           "{{name}}\n",
+		"site.yaml":                            "locale: de\ndate_format: 2. January 2006\n",
+		"content/2021-10-18.launch/body.md":    "Hello\n",
+		"content/2021-03-01.lancement/body.md": "---\nlocale: fr_FR\n---\nBonjour\n",
+		"content/2021-05-02.release/body.md":   "---\nlocale: en\ndate_format: Jan 2\n---\nHello\n",
+	})
+
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+
+	values := PageValues(tacker.FindPage("/launch"), nil)
+	assert.Equal(t, "18. Oktober 2021", values["date"])
+	assert.Equal(t, "18. Oktober 2021", values["date_long"])
+	assert.Equal(t, "18. Okt.", values["date_short"])
+	assert.Equal(t, "2021-10-18", values["date_iso"])
+	assert.Equal(t, "Oktober", values["month"])
+	assert.Equal(t, "2021", values["year"])
+
+	values = PageValues(tacker.FindPage("/lancement"), nil)
+	assert.Equal(t, "1. mars 2021", values["date"])
+	assert.Equal(t, "1 mars 2021", values["date_long"])
+	assert.Equal(t, "1 mars", values["date_short"])
+
+	values = PageValues(tacker.FindPage("/release"), nil)
+	assert.Equal(t, "May 2", values["date"])
+	assert.Equal(t, "May 2, 2021", values["date_long"])
+	assert.Equal(t, "May", values["month"])
+
+	list := PageListValues(tacker.Posts, nil)
+	assert.Equal(t, "18. Okt.", list[0]["date_short"])
+	assert.Equal(t, "2021-03-01", list[2]["date_iso"])
+
+	monday := time.Date(2021, 10, 18, 14, 30, 0, 0, time.UTC)
+	assert.Equal(t, "lundi 18 octobre, 14:30", locales["fr"].format(monday, "Monday 2 January, 15:04"))
+	assert.Equal(t, "Mo., 18.10.", locales["de"].format(monday, "Mon, 02.01."))
+	assert.Equal(t, "Monthly: Okt.", locales["de"].format(monday, "Monthly: Jan"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "site.yaml"), []byte("locale: tlh\n"), 0644))
+	assert.Error(t, tacker.Reload())
+}
+
+func TestPagination(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	site := filepath.Join(filepath.Dir(filename), "tests", "test-pagination")
+
+	tacker, err := NewTacker(site)
+	assert.NoError(t, err)
+	tacker.Logger = nil
+	assert.NoError(t, tacker.Tack())
+	AssertDirEquals(t, filepath.Join(site, "output.expected"), filepath.Join(site, "output"))
+	assert.NoFileExists(t, filepath.Join(site, "output", "blog", "page", "2", FeedFile))
+
+	second := tacker.FindPage("/blog/page/2")
+	assert.NotNil(t, second)
+	ctx := RenderContext(second)
+	pagination := ctx["pagination"].(map[string]interface{})
+	assert.Equal(t, 2, pagination["current"])
+	assert.Equal(t, 3, pagination["total"])
+	assert.Equal(t, "/blog", pagination["previous_permalink"])
+	assert.Equal(t, "/blog/page/3", pagination["next_permalink"])
+	assert.Len(t, pagination["pages"], 3)
+	assert.Len(t, ctx["posts"], 2)
+	assert.Equal(t, "/blog/feed.xml", ctx["feed_permalink"])
+
+	last := RenderContext(tacker.FindPage("/blog/page/3"))
+	assert.Len(t, last["posts"], 1)
+	assert.Equal(t, "", last["pagination"].(map[string]interface{})["next_permalink"])
+
+	misc := RenderContext(tacker.FindPage("/tags/misc"))
+	assert.Equal(t, 1, misc["pagination"].(map[string]interface{})["total"])
+	assert.Nil(t, tacker.FindPage("/tags/misc/page/2"))
+	assert.NotNil(t, tacker.FindPage("/tags/news/page/2"))
+
+	assert.NotContains(t, RenderContext(tacker.FindPage("/")), "pagination")
+}
+
+func TestPaginationCopies(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache":          "{{#menu}}{{name}}{{#current}}*{{/current}} {{/menu}}\n{{{body}}}",
+		"content/1.blog/body.md":              "---\npaginate: 1\n---\n![Header](header.png) [Top](#top) [About](../about)\n",
+		"content/1.blog/header.png":           "PNG",
+		"content/1.blog/2021-01-01.a/body.md": "A",
+		"content/1.blog/2021-01-02.b/body.md": "B",
+		"content/2.about/body.md":             "About",
+	})
+
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+	tacker.Logger = nil
+	assert.NoError(t, tacker.Tack())
+
+	first, err := os.ReadFile(filepath.Join(base, TargetDir, "blog", "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(first), "Blog* About \n")
+	assert.Contains(t, string(first), `<img src="header.png" alt="Header">`)
+
+	second, err := os.ReadFile(filepath.Join(base, TargetDir, "blog", "page", "2", "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(second), "Blog* About \n")
+	assert.Contains(t, string(second), `<img src="/blog/header.png" alt="Header">`)
+	assert.Contains(t, string(second), `<a href="#top">Top</a>`)
+	assert.Contains(t, string(second), `<a href="/about">About</a>`)
+	assert.NoFileExists(t, filepath.Join(base, TargetDir, "blog", "page", "2", "header.png"))
+
+	assert.Equal(t, tacker.FindPage("/blog"), tacker.FindPage("/blog/page/2").FirstPage())
+	assert.Nil(t, tacker.FindPage("/blog").FirstPage())
+
+	// a child page would be overwritten by the further pages of posts
+	assert.NoError(t, os.MkdirAll(filepath.Join(base, ContentDir, "1.blog", "page"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(base, ContentDir, "1.blog", "page", "body.md"), []byte("Page"), 0644))
+	assert.EqualError(t, tacker.Reload(), "unable to paginate /blog: conflicts with child page /blog/page")
+}
+
+func TestAdjacentPages(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	tacker, err := NewTacker(filepath.Join(filepath.Dir(filename), "tests", "test-pagination"))
+	assert.NoError(t, err)
+
+	permalink := func(v interface{}) interface{} {
+		if m, ok := v.(map[string]interface{}); ok && m != nil {
+			return m["permalink"]
+		}
+		return nil
+	}
+
+	ctx := RenderContext(tacker.FindPage("/blog/post-3"))
+	assert.Equal(t, "/blog/post-2", permalink(ctx["previous_post"]))
+	assert.Equal(t, "/blog/post-4", permalink(ctx["next_post"]))
+	ctx = RenderContext(tacker.FindPage("/blog/post-5"))
+	assert.Equal(t, "/blog/post-4", permalink(ctx["previous_post"]))
+	assert.Nil(t, permalink(ctx["next_post"]))
+	assert.NotContains(t, ctx, "previous")
+
+	// neighbours across the boundaries of paginated lists
+	posts := RenderContext(tacker.FindPage("/blog/page/2"))["posts"].([]map[string]interface{})
+	assert.Equal(t, "/blog/post-3", posts[0]["permalink"])
+	assert.Equal(t, "/blog/post-4", permalink(posts[0]["next_post"]))
+	assert.Equal(t, "/blog/post-1", permalink(posts[1]["previous_post"]))
+
+	// neighbours within the posts of a tag
+	posts = RenderContext(tacker.FindPage("/tags/news"))["posts"].([]map[string]interface{})
+	assert.Equal(t, "/blog/post-1", posts[0]["permalink"])
+	assert.Nil(t, permalink(posts[0]["previous_post"]))
+	assert.Equal(t, "/blog/post-2", permalink(posts[0]["next_post"]))
+	posts = RenderContext(tacker.FindPage("/tags/misc"))["posts"].([]map[string]interface{})
+	assert.Equal(t, "/blog/post-4", permalink(posts[1]["previous_post"]))
+	assert.Nil(t, permalink(posts[1]["next_post"]))
+
+	// the chronologies are built when loading the site and shared
+	blog := tacker.FindPage("/blog")
+	assert.NotNil(t, blog.chronology)
+	assert.Same(t, blog.chronology, tacker.FindPage("/blog/page/2").listedChronology())
+	assert.Same(t, blog.chronology, tacker.FindPage("/blog/post-3").siblingChronology())
+
+	tacker, err = NewTacker(filepath.Join(filepath.Dir(filename), "tests", "minimal"))
+	assert.NoError(t, err)
+	ctx = RenderContext(tacker.FindPage("/help"))
+	assert.Equal(t, "/products", permalink(ctx["previous"]))
+	assert.Equal(t, "/about", permalink(ctx["next"]))
+	ctx = RenderContext(tacker.FindPage("/about"))
+	assert.Equal(t, "/help", permalink(ctx["previous"]))
+	assert.Nil(t, permalink(ctx["next"]))
+	assert.NotContains(t, ctx, "previous_post")
+
+	// missing neighbours do not fail in strict mode
+	tpl, err := mustache.ParseString("{{#previous}}{{permalink}}{{/previous}}|{{#next}}{{permalink}}{{/next}}")
+	assert.NoError(t, err)
+	defer useMissingVariables(false)()
+	out, err := tpl.Render(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "/help|", out)
+}
+
+func TestTemplateRenderStrictMode(t *testing.T) {
+	base := writeSite(t, map[string]string{
+		"templates/default.mustache": "{{name}}{{undefined}}",
+		"content/a/body.md":          "A",
+	})
+
+	tacker, err := NewTacker(base)
+	assert.NoError(t, err)
+	page := tacker.FindPage("/a")
+	tpl, err := tacker.FindTemplate(page.Template)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, tpl.Render(page, buf))
+	assert.Equal(t, "A", buf.String())
+
+	tacker.Strict = true
+	assert.Error(t, tpl.Render(page, &bytes.Buffer{}))
+}
@@ -1,15 +1,206 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cbroglie/mustache"
 )
 
 type Template struct {
 	*mustache.Template
+	// Filename is the path of the template file itself.
+	Filename string
+	// Partials contains the paths of all partial files used by this template,
+	// including the ones used by other partials.
+	Partials []string
+
+	fingerprint string
+	// incomplete is set if the template references partials which do not
+	// exist (yet).
+	incomplete bool
+}
+
+// DependsOn returns true if the template was loaded from the given file or
+// uses it as a partial.
+func (t *Template) DependsOn(filename string) bool {
+	if t.Filename == filename {
+		return true
+	}
+	for _, i := range t.Partials {
+		if i == filename {
+			return true
+		}
+	}
+
+	return false
+}
+
+// partialProvider resolves partials from the template directory the same way
+// mustache.FileProvider does, but reads each partial only once and keeps track
+// of the files used. Once all partials of a template are resolved, the
+// provider is sealed and serves them from memory without locking, as
+// mustache asks for the partials again whenever the template is rendered.
+type partialProvider struct {
+	source   source
+	dir      string
+	mutex    sync.Mutex
+	sealed   bool
+	content  map[string]string
+	files    map[string]string
+	resolved map[string]struct{}
+}
+
+func newPartialProvider(src source, dir string) *partialProvider {
+	return &partialProvider{
+		source:   src,
+		dir:      dir,
+		content:  map[string]string{},
+		files:    map[string]string{},
+		resolved: map[string]struct{}{},
+	}
+}
+
+func (pp *partialProvider) Get(name string) (string, error) {
+	if pp.sealed {
+		return pp.content[name], nil
+	}
+
+	pp.mutex.Lock()
+	defer pp.mutex.Unlock()
+
+	if data, ok := pp.content[name]; ok {
+		return data, nil
+	}
+
+	fn := pp.source.firstFileWithExtension(pp.dir, name, TemplateExtensions...)
+	if fn == "" {
+		pp.content[name] = ""
+		return "", nil
+	}
+
+	data, err := pp.source.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	pp.content[name] = string(data)
+	pp.files[name] = fn
+
+	return string(data), nil
+}
+
+// resolve recursively loads all partials referenced by the given tags, so
+// that the dependencies of a template are known before rendering it.
+func (pp *partialProvider) resolve(tags []mustache.Tag) error {
+	for _, tag := range tags {
+		switch tag.Type() {
+		case mustache.Section, mustache.InvertedSection:
+			if err := pp.resolve(tag.Tags()); err != nil {
+				return err
+			}
+		case mustache.Partial:
+			if _, ok := pp.resolved[tag.Name()]; ok {
+				continue
+			}
+			pp.resolved[tag.Name()] = struct{}{}
+			data, err := pp.Get(tag.Name())
+			if err != nil {
+				return err
+			}
+			partial, err := mustache.ParseStringPartials(data, pp)
+			if err != nil {
+				return fmt.Errorf("partial '%s': %w", tag.Name(), newSourceError(pp.files[tag.Name()], err))
+			}
+			if err := pp.resolve(partial.Tags()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseTemplate(src source, filename string, dir string) (*Template, error) {
+	data, err := src.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := newPartialProvider(src, dir)
+	tpl, err := mustache.ParseStringPartials(string(data), provider)
+	if err != nil {
+		return nil, newSourceError(filename, err)
+	}
+	if err := provider.resolve(tpl.Tags()); err != nil {
+		return nil, err
+	}
+	provider.sealed = true
+
+	names := []string{}
+	for name := range provider.content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", filepath.Base(filename), data)
+	result := &Template{Template: tpl, Filename: filename}
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, provider.content[name])
+		if fn, ok := provider.files[name]; ok {
+			result.Partials = append(result.Partials, fn)
+		} else {
+			result.incomplete = true
+		}
+	}
+	sort.Strings(result.Partials)
+	result.fingerprint = hex.EncodeToString(h.Sum(nil))
+
+	return result, nil
+}
+
+// missingVariables guards the global mustache.AllowMissingVariables setting,
+// so that multiple pages (or even multiple Tackers) can be rendered
+// concurrently as long as they use the same setting.
+var missingVariables struct {
+	sync.Mutex
+	cond  *sync.Cond
+	users int
+}
+
+func init() {
+	missingVariables.cond = sync.NewCond(&missingVariables.Mutex)
+}
+
+// useMissingVariables waits until no renderer with a different setting is
+// active anymore and configures mustache to allow (or disallow) rendering
+// undefined variables. The returned function has to be called once rendering
+// is finished.
+func useMissingVariables(allow bool) func() {
+	missingVariables.Lock()
+	for missingVariables.users > 0 && mustache.AllowMissingVariables != allow {
+		missingVariables.cond.Wait()
+	}
+	mustache.AllowMissingVariables = allow
+	missingVariables.users++
+	missingVariables.Unlock()
+
+	return func() {
+		missingVariables.Lock()
+		missingVariables.users--
+		if missingVariables.users == 0 {
+			missingVariables.cond.Broadcast()
+		}
+		missingVariables.Unlock()
+	}
 }
 
 func PageValues(p *Page, ctx *Page) map[string]interface{} {
@@ -26,26 +217,70 @@ func PageValues(p *Page, ctx *Page) map[string]interface{} {
 		data[k] = v
 	}
 
-	data["permalink"] = p.Permalink()
+	data["permalink"] = p.Tacker.Link(p.Permalink())
+	if url := p.Tacker.URL(p.Permalink()); url != "" {
+		data["url"] = url
+	}
 	data["slug"] = p.Slug
-	data["current"] = ctx != nil && ctx == p
+	// further pages of posts are the current page as well
+	data["current"] = ctx != nil && (ctx == p || ctx.first == p)
 	data["root"] = p.Root()
+	data["draft"] = p.Draft()
+	data["scheduled"] = p.Scheduled()
 	if p.Post() {
-		data["date"] = p.Date.Format("2006-01-02")
+		loc, err := p.locale()
+		if err != nil {
+			// already reported when loading the site
+			loc = locales[DefaultLocale]
+		}
+		if _, ok := p.Variables["date"]; !ok {
+			data["date"] = loc.format(p.Date, p.dateFormat())
+		}
+		data["date_long"] = loc.format(p.Date, loc.long)
+		data["date_short"] = loc.format(p.Date, loc.short)
+		data["date_iso"] = p.Date.Format("2006-01-02")
+		data["timestamp"] = p.Date.Format(time.RFC3339)
 		data["year"] = p.Date.Format("2006")
-		data["month"] = p.Date.Format("January")
+		data["month"] = loc.months[p.Date.Month()-1]
 	}
 	data["tags"] = TagList(p)
+	if link := p.FeedPermalink(); link != "" {
+		data["feed_permalink"] = p.Tacker.Link(link)
+		data["feed_url"] = p.Tacker.URL(link)
+	}
 
 	return data
 }
 
 func PageListValues(pages []*Page, ctx *Page) []map[string]interface{} {
+	return pageListValues(pages, newChronology(pages), ctx)
+}
+
+// pageListValues returns the values of the given pages. The previous and next
+// posts of each post are looked up in the chronology of the complete list, if
+// pages is only a part of it.
+func pageListValues(pages []*Page, chronology *chronology, ctx *Page) []map[string]interface{} {
+	// the posts of a list are each other's neighbours, so the values of
+	// every page are only built once and shared
+	values := map[*Page]map[string]interface{}{}
+	pageValues := func(p *Page) map[string]interface{} {
+		if p == nil {
+			return nil
+		}
+		if _, ok := values[p]; !ok {
+			values[p] = PageValues(p, ctx)
+		}
+		return values[p]
+	}
+
 	r := []map[string]interface{}{}
 	var year int
 	var month string
 	for idx, i := range pages {
-		data := PageValues(i, ctx)
+		data := map[string]interface{}{}
+		for k, v := range pageValues(i) {
+			data[k] = v
+		}
 		data["first"] = idx == 0
 		data["last"] = idx == len(pages)-1
 		if i.Post() {
@@ -61,6 +296,10 @@ func PageListValues(pages []*Page, ctx *Page) []map[string]interface{} {
 
 			data["last_in_year"] = next == nil || year != next.Date.Year()
 			data["last_in_month"] = next == nil || month != next.Date.Format("2006-January")
+
+			previousPost, nextPost := chronology.adjacent(i)
+			data["previous_post"] = pageValues(previousPost)
+			data["next_post"] = pageValues(nextPost)
 		}
 		r = append(r, data)
 	}
@@ -94,18 +333,34 @@ func TagList(page *Page) []map[string]interface{} {
 
 	r := []map[string]interface{}{}
 	for _, i := range list {
-		r = append(r, map[string]interface{}{
+		data := map[string]interface{}{
 			"name":      i.Name,
 			"slug":      i.Slug,
 			"count":     i.Count,
-			"permalink": i.Permalink,
-		})
+			"permalink": page.Tacker.Link(i.Permalink),
+		}
+		if url := page.Tacker.URL(i.Permalink); url != "" {
+			data["url"] = url
+		}
+		r = append(r, data)
 	}
 
 	return r
 }
 
+// Render fills the template with the rendering context of the given page and
+// writes the result to w. Undefined variables are rendered as empty strings,
+// unless the page's Tacker is in strict mode.
 func (t *Template) Render(page *Page, w io.Writer) error {
+	defer useMissingVariables(!page.Tacker.Strict)()
+	return t.Template.FRender(w, RenderContext(page))
+}
+
+// RenderContext returns all variables available to the template when
+// rendering the given page: The site metadata, the page's own variables, and
+// the values generated by tack, like the page's parent, siblings, children,
+// navigation, menu, ancestors and posts.
+func RenderContext(page *Page) map[string]interface{} {
 	ctx := map[string]interface{}{}
 
 	for k, v := range page.Tacker.Metadata {
@@ -116,6 +371,7 @@ func (t *Template) Render(page *Page, w io.Writer) error {
 		ctx[k] = v
 	}
 
+	ctx["base_path"] = page.Tacker.BasePath()
 	ctx["parent"] = PageValues(page.Parent, page)
 	ctx["siblings"] = PageListValues(page.Siblings(), page)
 	ctx["children"] = PageListValues(page.Children, page)
@@ -123,21 +379,52 @@ func (t *Template) Render(page *Page, w io.Writer) error {
 	ctx["menu"] = PageListValues(page.SiblingsAndMe, page)
 	ctx["ancestors"] = PageListValues(page.Ancestors(), page)
 
-	if !page.Post() && (!page.addTagPages || len(page.Posts) > 0) {
-		posts := page.Posts
-		if len(posts) == 0 && page.Parent != nil && len(page.Parent.Posts) > 0 {
-			posts = page.Parent.Posts
-		} else if len(posts) == 0 && page.Parent == nil {
-			for _, i := range page.Tacker.Posts {
-				if i.Parent == nil {
-					posts = append(posts, i)
-				}
-			}
+	if page.listsPosts() {
+		all := page.listedPosts()
+		posts := all
+		if page.pageSize() > 0 {
+			ctx["pagination"] = PaginationValues(page)
+			posts = page.pageOfPosts(all)
+		} else {
+			posts = limitPageList(all, page, "posts_limit")
 		}
-		ctx["posts"] = PageListValues(limitPageList(posts, page, "posts_limit"), page)
+		ctx["posts"] = pageListValues(posts, page.listedChronology(), page)
 	}
 
-	return t.Template.FRender(w, ctx)
+	if page.Post() {
+		previousPost, nextPost := page.siblingChronology().adjacent(page)
+		ctx["previous_post"] = PageValues(previousPost, page)
+		ctx["next_post"] = PageValues(nextPost, page)
+	} else if !page.Floating {
+		previous, next := page.adjacentSiblings()
+		ctx["previous"] = PageValues(previous, page)
+		ctx["next"] = PageValues(next, page)
+	}
+
+	return ctx
+}
+
+// ContextSources maps the names of all variables of the page's rendering
+// context which have been read from the site metadata or the page's files to
+// the respective file. Variables generated (or overridden) by tack are not
+// included.
+func ContextSources(page *Page, ctx map[string]interface{}) map[string]string {
+	r := map[string]string{}
+
+	for k, src := range page.Tacker.MetadataSources {
+		if reflect.DeepEqual(ctx[k], page.Tacker.Metadata[k]) {
+			r[k] = src
+		}
+	}
+	for k, src := range page.Sources {
+		if reflect.DeepEqual(ctx[k], page.Variables[k]) {
+			r[k] = src
+		} else {
+			delete(r, k)
+		}
+	}
+
+	return r
 }
 
 func limitPageList(list []*Page, page *Page, name string) []*Page {
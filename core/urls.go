@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// BaseURL returns the parsed `base_url` site metadata variable, which needs to
+// be an absolute URL, ie. https://example.org/~team/. If the variable is not
+// set, nil is returned. The variable is parsed when loading the site, which
+// fails if it is invalid.
+func (t *Tacker) BaseURL() (*url.URL, error) {
+	if t.baseURL == nil {
+		return nil, nil
+	}
+
+	// a copy, so that callers cannot modify it
+	u := *t.baseURL
+	return &u, nil
+}
+
+// loadBaseURL parses the `base_url` site metadata variable for BaseURL().
+func (t *Tacker) loadBaseURL() error {
+	t.baseURL = nil
+	s := t.stringSetting("base_url")
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid base_url: %s (needs to be an absolute URL)", s)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+	t.baseURL = u
+
+	return nil
+}
+
+// BasePath returns the path the site is hosted under according to the
+// `base_url` site metadata variable, without a trailing slash. For sites
+// hosted at the root of a domain, an empty string is returned.
+func (t *Tacker) BasePath() string {
+	if t.baseURL == nil {
+		return ""
+	}
+
+	return t.baseURL.Path
+}
+
+// URL returns the absolute URL of the given permalink, or an empty string if
+// no `base_url` is set.
+func (t *Tacker) URL(permalink string) string {
+	if t.baseURL == nil {
+		return ""
+	}
+
+	return t.baseURL.String() + permalink
+}
+
+// Link returns the link to the given permalink to be used in templates: If the
+// `absolute_permalinks` site metadata variable is set to `true`, this is the
+// absolute URL. Otherwise, the permalink is prefixed with the base path, so
+// that links work for sites hosted below a sub-path.
+func (t *Tacker) Link(permalink string) string {
+	if permalink == "" {
+		return ""
+	}
+	if t.Metadata["absolute_permalinks"] == true {
+		if u := t.URL(permalink); u != "" {
+			return u
+		}
+	}
+
+	return t.BasePath() + permalink
+}
+
+// htmlLinks matches the link targets and image sources in HTML content.
+var htmlLinks = regexp.MustCompile(`(\s(?:href|src)=")([^"]*)"`)
+
+// resolveLinks resolves all relative link targets and image sources in the
+// HTML content s against the given base URL. Links to fragments of the same
+// document, ie. “#top”, are kept, unless resolveFragments is set.
+func resolveLinks(s string, base *url.URL, resolveFragments bool) string {
+	return htmlLinks.ReplaceAllStringFunc(s, func(attr string) string {
+		m := htmlLinks.FindStringSubmatch(attr)
+		ref, err := url.Parse(html.UnescapeString(m[2]))
+		if err != nil || ref.IsAbs() {
+			return attr
+		}
+		if !resolveFragments && strings.HasPrefix(m[2], "#") {
+			return attr
+		}
+
+		return m[1] + html.EscapeString(base.ResolveReference(ref).String()) + `"`
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	_ "time/tzdata"
 
 	"github.com/roblillack/tack/commands"
 	"github.com/roblillack/tack/core"
@@ -15,32 +16,23 @@ func Fatalf(format string, args ...interface{}) {
 }
 
 func main() {
-	cmd := commands.Tack
-	args := []string{}
-
 	flag.Parse()
 
-	if len(flag.Args()) >= 1 {
-		found := false
-		for _, i := range commands.List {
-			if flag.Arg(0) == i.Name {
-				cmd = i.Fn
-				found = true
-				break
-			}
-		}
-		if found {
-			args = flag.Args()[1:]
-		} else if core.DirExists(flag.Arg(0)) {
-			args = flag.Args()
-		} else {
-			fmt.Fprintf(os.Stderr, "Not a known verb or site directory: %s\n\n", flag.Arg(0))
+	cmd := commands.FindCommand("tack")
+	args := flag.Args()
+
+	if len(args) >= 1 {
+		if c := commands.FindCommand(args[0]); c != nil {
+			cmd = c
+			args = args[1:]
+		} else if !core.DirExists(args[0]) {
+			fmt.Fprintf(os.Stderr, "Not a known verb or site directory: %s\n\n", args[0])
 			_ = commands.Help()
 			os.Exit(1)
 		}
 	}
 
-	if err := cmd(args...); err != nil {
+	if err := cmd.Run(args); err != nil {
 		Fatalf(err.Error())
 	}
 }